@@ -3,8 +3,11 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/parkr/github-utils/contributions"
@@ -13,17 +16,114 @@ import (
 
 var oneWeekAgo = time.Now().AddDate(0, 0, -7).Format("2006-01-02")
 
+// The extension of a gist for each format.
+var extensions = map[string]string{
+	"markdown": ".md",
+	"json":     ".json",
+	"csv":      ".csv",
+	"html":     ".html",
+}
+
 func main() {
 	var login string
-	flag.StringVar(&login, "login", "", "The GitHub username of the user, e.g. 'defunkt'")
+	flag.StringVar(&login, "login", "", "The GitHub username of the user, e.g. 'defunkt', or several separated by commas")
+	var team string
+	flag.StringVar(&team, "team", "", "A team to report on instead, e.g. 'jekyll/maintainers'")
+	var groupBy string
+	flag.StringVar(&groupBy, "group-by", "", "For several users, also group the report by 'person' or 'repo'")
 	var startDate string
 	flag.StringVar(&startDate, "since", oneWeekAgo, "The start date to look for contributions")
+	var endDate string
+	flag.StringVar(&endDate, "until", "", "The last date to look for contributions, if not today")
+	var periodName string
+	flag.StringVar(&periodName, "period", "", "A period to look for contributions in instead of -since and -until: "+strings.Join(contributions.RelativePeriods, ", "))
+	var breakdown string
+	flag.StringVar(&breakdown, "breakdown", "", "Also break the report down by "+strings.Join(contributions.Intervals, " or "))
+	var compare bool
+	flag.BoolVar(&compare, "compare", false, "Compare the report with the previous period of the same length")
 	var owner string
-	flag.StringVar(&owner, "owner", "", "The owner to which to scope our contribution scopes, e.g. 'github'")
+	flag.StringVar(&owner, "owner", "", "Only count contributions to repos of these users or orgs, separated by commas, e.g. 'github'")
+	var orgs string
+	flag.StringVar(&orgs, "org", "", "Only count contributions to repos of these orgs, separated by commas")
+	var repos string
+	flag.StringVar(&repos, "repo", "", "Only count contributions to these repos, separated by commas, e.g. 'jekyll/jekyll'")
+	var excludeRepos string
+	flag.StringVar(&excludeRepos, "exclude-repo", "", "Don't count contributions to these repos, separated by commas")
+	var labels string
+	flag.StringVar(&labels, "label", "", "Only count issues & PR's with all of these labels, separated by commas")
+	var excludeLabels string
+	flag.StringVar(&excludeLabels, "exclude-label", "", "Don't count issues & PR's with any of these labels, separated by commas")
+	var excludeBots bool
+	flag.BoolVar(&excludeBots, "exclude-bots", false, "Don't count issues & PR's opened by bots like Dependabot")
+	var format string
+	flag.StringVar(&format, "format", "markdown", "The format of the report: "+strings.Join(contributions.Formats, ", "))
+	var templateFile string
+	flag.StringVar(&templateFile, "template", "", "A Go text/template file to render the report with, instead of -format")
+	var publishTarget string
+	flag.StringVar(&publishTarget, "publish", "", "Publish the report instead of printing it: 'gist', 'issue:owner/repo#123', 'radar:owner/repo' or 'file:owner/repo/path.md'")
 	flag.Parse()
 
-	if login == "" {
-		log.Fatal("error: you must specify a -login")
+	if login == "" && team == "" {
+		log.Fatal("error: you must specify a -login or -team")
+	}
+
+	// The default -since is the same as -period=last-week, which keeps the
+	// same key when publishing again later in the week.
+	sinceSet := false
+	flag.Visit(func(f *flag.Flag) { sinceSet = sinceSet || f.Name == "since" })
+	if periodName == "" && !sinceSet && endDate == "" {
+		periodName = "last-week"
+	}
+
+	var period contributions.Period
+	var err error
+	if periodName != "" {
+		period, err = contributions.RelativePeriod(periodName, time.Now())
+	} else {
+		period, err = contributions.ParsePeriod(startDate, endDate)
+	}
+	if err != nil {
+		log.Fatalf("fatal: %v", err)
+	}
+
+	scope := contributions.Scope{
+		Orgs:          splitList(orgs),
+		Users:         splitList(owner),
+		Repos:         splitList(repos),
+		ExcludeRepos:  splitList(excludeRepos),
+		Labels:        splitList(labels),
+		ExcludeLabels: splitList(excludeLabels),
+		ExcludeBots:   excludeBots,
+	}
+	if err := scope.Validate(); err != nil {
+		log.Fatalf("fatal: %v", err)
+	}
+
+	var renderer contributions.Renderer
+	if templateFile != "" {
+		var tmpl []byte
+		if tmpl, err = os.ReadFile(templateFile); err != nil {
+			log.Fatalf("fatal: could not read -template: %v", err)
+		}
+		renderer, err = contributions.NewTemplateRenderer(string(tmpl))
+	} else {
+		renderer, err = contributions.NewRenderer(format)
+	}
+	if err != nil {
+		log.Fatalf("fatal: %v", err)
+	}
+
+	var publisher contributions.Publisher
+	if publishTarget != "" {
+		extension, rendering := extensions[format], format
+		if templateFile != "" {
+			extension, rendering = ".md", "template:"+templateFile
+		}
+		// Reports rendered or grouped differently are published separately.
+		options := []string{rendering, groupBy, breakdown, strconv.FormatBool(compare)}
+		if publisher, err = contributions.NewPublisher(publishTarget, extension, period, scope, options...); err != nil {
+			log.Fatalf("fatal: %v", err)
+		}
 	}
 
 	client, err := gh.NewDefaultClient()
@@ -31,8 +131,60 @@ func main() {
 		log.Fatalf("fatal: could not initialize client: %v", err)
 	}
 
-	err = contributions.New(client, login, startDate, owner).Write(os.Stdout)
+	name, logins := login, strings.Split(login, ",")
+	if team != "" {
+		name = team
+		if logins, err = contributions.TeamMembers(client, team); err != nil {
+			log.Fatalf("fatal: could not list members of %s: %v", team, err)
+		}
+	}
+	build := func(period contributions.Period) (*contributions.Report, error) {
+		if team != "" || len(logins) > 1 {
+			return contributions.TeamReport(client, name, logins, period, scope, groupBy)
+		}
+		return contributions.New(client, login, period, scope).Report()
+	}
+
+	var report *contributions.Report
+	if breakdown != "" {
+		report, err = contributions.BreakdownReport(period, breakdown, time.Now(), build)
+	} else {
+		report, err = build(period)
+	}
+	if err == nil && compare {
+		err = contributions.AddComparison(report, time.Now(), build)
+	}
 	if err != nil {
 		log.Fatalf("error: %+v", err)
 	}
+
+	if publisher == nil {
+		if err := renderer.Render(os.Stdout, report); err != nil {
+			log.Fatalf("error: %+v", err)
+		}
+		return
+	}
+	var rendered strings.Builder
+	if err := renderer.Render(&rendered, report); err != nil {
+		log.Fatalf("error: %+v", err)
+	}
+	url, err := publisher.Publish(client, report, rendered.String())
+	if err != nil {
+		log.Fatalf("error: could not publish report: %+v", err)
+	}
+	fmt.Println(url)
+}
+
+// Splits a comma-separated flag into its values, if it's set.
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	values := []string{}
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
 }
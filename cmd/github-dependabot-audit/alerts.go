@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v88/github"
+	"github.com/parkr/github-utils/gh"
+)
+
+// Dependabot alert severities, most severe first.
+var alertSeverities = []string{"critical", "high", "medium", "low"}
+
+type securitySettings struct {
+	VulnerabilityAlerts    bool
+	AutomatedSecurityFixes bool
+}
+
+type alertSummary struct {
+	Open       int
+	BySeverity map[string]int
+	OldestOpen time.Time
+}
+
+func (s alertSummary) String() string {
+	if s.Open == 0 {
+		return "0 open alerts"
+	}
+	counts := []string{}
+	for _, severity := range alertSeverities {
+		if s.BySeverity[severity] > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", s.BySeverity[severity], severity))
+		}
+	}
+	return fmt.Sprintf("%d open alerts (%s), oldest open for %d days",
+		s.Open, strings.Join(counts, ", "), int(time.Since(s.OldestOpen).Hours()/24))
+}
+
+func readSecuritySettings(ctx context.Context, client *gh.Client, githubLogin string, repoName string) (securitySettings, error) {
+	settings := securitySettings{}
+
+	enabled, _, err := client.Repositories.GetVulnerabilityAlerts(ctx, githubLogin, repoName)
+	if err != nil {
+		return settings, fmt.Errorf("error fetching vulnerability alerts setting: %w", err)
+	}
+	settings.VulnerabilityAlerts = enabled
+
+	fixes, _, err := client.Repositories.GetAutomatedSecurityFixes(ctx, githubLogin, repoName)
+	if err != nil {
+		return settings, fmt.Errorf("error fetching automated security fixes setting: %w", err)
+	}
+	settings.AutomatedSecurityFixes = fixes.GetEnabled()
+
+	return settings, nil
+}
+
+// Turns on vulnerability alerts and automated security fixes, if they're not
+// already on. Security fixes require vulnerability alerts, so enable those first.
+func enableMissingSecuritySettings(ctx context.Context, client *gh.Client, githubLogin string, repoName string, settings securitySettings, report *repoReport) (securitySettings, error) {
+	if !settings.VulnerabilityAlerts {
+		if _, err := client.Repositories.EnableVulnerabilityAlerts(ctx, githubLogin, repoName); err != nil {
+			return settings, fmt.Errorf("error enabling vulnerability alerts: %w", err)
+		}
+		settings.VulnerabilityAlerts = true
+		report.Add("enabled vulnerability alerts")
+	}
+	if !settings.AutomatedSecurityFixes {
+		if _, err := client.Repositories.EnableAutomatedSecurityFixes(ctx, githubLogin, repoName); err != nil {
+			return settings, fmt.Errorf("error enabling automated security fixes: %w", err)
+		}
+		settings.AutomatedSecurityFixes = true
+		report.Add("enabled automated security fixes")
+	}
+	return settings, nil
+}
+
+func summarizeOpenAlerts(ctx context.Context, client *gh.Client, githubLogin string, repoName string) (alertSummary, error) {
+	summary := alertSummary{BySeverity: map[string]int{}}
+
+	opts := &github.ListAlertsOptions{
+		State:             github.String("open"),
+		ListCursorOptions: github.ListCursorOptions{PerPage: 100},
+	}
+	for {
+		alerts, resp, err := client.Dependabot.ListRepoAlerts(ctx, githubLogin, repoName, opts)
+		if err != nil {
+			return summary, fmt.Errorf("error listing dependabot alerts: %w", err)
+		}
+
+		for _, alert := range alerts {
+			summary.Open++
+			summary.BySeverity[alert.GetSecurityAdvisory().GetSeverity()]++
+			createdAt := alert.GetCreatedAt().Time
+			if summary.OldestOpen.IsZero() || createdAt.Before(summary.OldestOpen) {
+				summary.OldestOpen = createdAt
+			}
+		}
+
+		if resp.After == "" {
+			break
+		}
+		opts.ListCursorOptions.After = resp.After
+	}
+
+	return summary, nil
+}
+
+func securityAuditForSingleRepo(ctx context.Context, client *gh.Client, githubLogin string, repoName string, report *repoReport) {
+	settings, err := readSecuritySettings(ctx, client, githubLogin, repoName)
+	if err != nil {
+		report.Fail("%w", err)
+		return
+	}
+
+	if enableSecuritySettings {
+		settings, err = enableMissingSecuritySettings(ctx, client, githubLogin, repoName, settings, report)
+		if err != nil {
+			report.Fail("%w", err)
+		}
+	}
+
+	if !settings.VulnerabilityAlerts {
+		report.Add("vulnerability alerts disabled")
+	}
+	if !settings.AutomatedSecurityFixes {
+		report.Add("automated security fixes disabled")
+	}
+
+	// Alerts can't be listed unless they're enabled.
+	if !settings.VulnerabilityAlerts {
+		return
+	}
+
+	summary, err := summarizeOpenAlerts(ctx, client, githubLogin, repoName)
+	if err != nil {
+		report.Fail("%w", err)
+		return
+	}
+	if summary.Open > 0 || verbose {
+		report.Add("%s", summary)
+	}
+}
@@ -4,15 +4,94 @@ import (
 	"context"
 	"log"
 	"net/http"
+	"strings"
 
 	"github.com/parkr/github-utils/gh"
 	"gopkg.in/yaml.v2"
 )
 
-func containsGitHubActions(ctx context.Context, client *gh.Client, githubLogin string, repoName string) bool {
-	path := ".github/workflows"
+// A listing of every file in a repo's default branch, fetched in a single
+// call to the Git Trees API. If GitHub truncated the tree, lookups for paths
+// missing from the listing fall back to the Contents API.
+type repoFiles struct {
+	ctx         context.Context
+	client      *gh.Client
+	githubLogin string
+	repoName    string
+
+	// Path to entry type ("blob" or "tree").
+	entries   map[string]string
+	truncated bool
+}
+
+func fetchRepoFiles(ctx context.Context, client *gh.Client, githubLogin string, repoName string) *repoFiles {
+	files := &repoFiles{
+		ctx:         ctx,
+		client:      client,
+		githubLogin: githubLogin,
+		repoName:    repoName,
+		entries:     map[string]string{},
+	}
+
+	tree, resp, err := client.Git.GetTree(ctx, githubLogin, repoName, "HEAD", true)
+	if resp != nil && resp.StatusCode == http.StatusConflict {
+		// The repository is empty.
+		return files
+	}
+	if err != nil {
+		log.Printf("[%s/%s]: error fetching tree, falling back to contents API: %v", githubLogin, repoName, err)
+		files.truncated = true
+		return files
+	}
+
+	for _, entry := range tree.Entries {
+		files.entries[entry.GetPath()] = entry.GetType()
+	}
+	files.truncated = tree.GetTruncated()
+	if files.truncated && verbose {
+		log.Printf("[%s/%s]: tree truncated at %d entries, some lookups will use the contents API", githubLogin, repoName, len(tree.Entries))
+	}
+
+	return files
+}
+
+// Reports whether a file exists at path.
+func (f *repoFiles) ContainsFile(path string) bool {
+	if f.entries[path] == "blob" {
+		return true
+	}
+	if !f.truncated {
+		return false
+	}
+	return containsFile(f.ctx, f.client, f.githubLogin, f.repoName, path)
+}
+
+// Reports whether the directory at path contains at least one file.
+func (f *repoFiles) ContainsFilesIn(path string) bool {
+	prefix := strings.TrimSuffix(path, "/") + "/"
+	for entryPath, entryType := range f.entries {
+		if entryType == "blob" && strings.HasPrefix(entryPath, prefix) {
+			return true
+		}
+	}
+	if !f.truncated {
+		return false
+	}
+	return containsFilesIn(f.ctx, f.client, f.githubLogin, f.repoName, path)
+}
+
+// Returns the decoded contents of the file at path, and whether it exists.
+// Files known not to exist are never fetched.
+func (f *repoFiles) ReadFile(path string) (string, bool) {
+	if !f.ContainsFile(path) {
+		return "", false
+	}
+	return readFile(f.ctx, f.client, f.githubLogin, f.repoName, path)
+}
+
+func containsFilesIn(ctx context.Context, client *gh.Client, githubLogin string, repoName string, path string) bool {
 	_, directoryContents, resp, err := client.Repositories.GetContents(ctx, githubLogin, repoName, path, nil)
-	if resp.StatusCode == http.StatusNotFound {
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
 		return false
 	}
 	if err != nil {
@@ -20,12 +99,12 @@ func containsGitHubActions(ctx context.Context, client *gh.Client, githubLogin s
 		return false
 	}
 
-	return len(directoryContents) > 0 // check for at least one action
+	return len(directoryContents) > 0
 }
 
 func containsFile(ctx context.Context, client *gh.Client, githubLogin string, repoName string, path string) bool {
 	fileContents, _, resp, err := client.Repositories.GetContents(ctx, githubLogin, repoName, path, nil)
-	if resp.StatusCode == http.StatusNotFound {
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
 		return false
 	}
 	if err != nil {
@@ -36,6 +115,28 @@ func containsFile(ctx context.Context, client *gh.Client, githubLogin string, re
 	return fileContents != nil
 }
 
+// Returns the decoded contents of the file at path, and whether it exists.
+func readFile(ctx context.Context, client *gh.Client, githubLogin string, repoName string, path string) (string, bool) {
+	fileContents, _, resp, err := client.Repositories.GetContents(ctx, githubLogin, repoName, path, nil)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return "", false
+	}
+	if err != nil {
+		log.Printf("[%s/%s]: error fetching %s: %v", githubLogin, repoName, path, err)
+		return "", false
+	}
+	if fileContents == nil {
+		return "", false
+	}
+
+	content, err := fileContents.GetContent()
+	if err != nil {
+		log.Printf("[%s/%s]: error decoding %s: %v", githubLogin, repoName, path, err)
+		return "", false
+	}
+	return content, true
+}
+
 type dependabotFileStructure struct {
 	Version int `yaml:"version"`
 	Updates []struct {
@@ -43,16 +144,21 @@ type dependabotFileStructure struct {
 	} `yaml:"updates"`
 }
 
-func readDependabotConfig(ctx context.Context, client *gh.Client, githubLogin string, repoName string) dependabotFileStructure {
-	fileContents, _, _, err := client.Repositories.GetContents(ctx, githubLogin, repoName, ".github/dependabot.yml", nil)
-	if err == nil {
-		content, err := fileContents.GetContent()
-		if err == nil {
-			dependabotFile := &dependabotFileStructure{}
-			if err := yaml.Unmarshal([]byte(content), &dependabotFile); err == nil {
-				return *dependabotFile
-			}
+// Dependabot accepts either extension for its configuration file.
+var dependabotConfigFiles = []string{".github/dependabot.yml", ".github/dependabot.yaml"}
+
+// Reads the Dependabot configuration for the repo, and whether it was found.
+func readDependabotConfig(files *repoFiles) (dependabotFileStructure, bool) {
+	for _, path := range dependabotConfigFiles {
+		content, ok := files.ReadFile(path)
+		if !ok {
+			continue
+		}
+		dependabotFile := dependabotFileStructure{}
+		if err := yaml.Unmarshal([]byte(content), &dependabotFile); err != nil {
+			log.Printf("[%s/%s]: unable to parse %s: %v", files.githubLogin, files.repoName, path, err)
 		}
+		return dependabotFile, true
 	}
-	return dependabotFileStructure{}
+	return dependabotFileStructure{}, false
 }
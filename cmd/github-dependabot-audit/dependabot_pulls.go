@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v88/github"
+	"github.com/parkr/github-utils/gh"
+)
+
+const dependabotLogin = "dependabot[bot]"
+
+// Options for the stale pull request report, set via flags.
+var (
+	staleAfter            = 14 * 24 * time.Hour
+	rebaseDependabotPulls = false
+	closeSupersededPulls  = false
+)
+
+// Matches titles like "Bump lodash from 4.17.20 to 4.17.21 in /web", with an
+// optional commit message prefix like "build(deps): ".
+var dependabotTitlePattern = regexp.MustCompile(`(?i)^(?:\S+(?:\(\S+\))?: )?(?:bump|update) (\S+) (?:requirement )?from \S+ to \S+(?: in (\S+))?`)
+
+type dependabotPull struct {
+	*github.PullRequest
+
+	Ecosystem      string
+	Dependency     string
+	Directory      string
+	CheckStatus    string
+	MergeableState string
+	SupersededBy   int
+}
+
+func (p dependabotPull) Age() time.Duration {
+	return time.Since(p.GetCreatedAt().Time)
+}
+
+func (p dependabotPull) Stale() bool {
+	return p.Age() > staleAfter
+}
+
+// Dependabot pushes to branches like "dependabot/npm_and_yarn/web/lodash-4.17.21",
+// so the ecosystem is the second path segment.
+func dependabotEcosystem(pr *github.PullRequest) string {
+	pieces := strings.Split(pr.GetHead().GetRef(), "/")
+	if len(pieces) < 3 || pieces[0] != "dependabot" {
+		return "unknown"
+	}
+	return pieces[1]
+}
+
+// Returns the dependency and directory the PR updates, parsed from titles like
+// "Bump lodash from 4.17.20 to 4.17.21 in /web". Grouped updates aren't
+// parsed and return an empty dependency.
+func dependabotDependency(pr *github.PullRequest) (string, string) {
+	matches := dependabotTitlePattern.FindStringSubmatch(pr.GetTitle())
+	if matches == nil {
+		return "", ""
+	}
+	directory := matches[2]
+	if directory == "" {
+		directory = "/"
+	}
+	return matches[1], directory
+}
+
+// Summarizes commit statuses and check runs for the ref as one of "success",
+// "failure", "pending" or "none".
+func checkStatus(ctx context.Context, client *gh.Client, githubLogin string, repoName string, ref string) (string, error) {
+	combinedStatus, _, err := client.Repositories.GetCombinedStatus(ctx, githubLogin, repoName, ref, nil)
+	if err != nil {
+		return "", fmt.Errorf("error fetching status for %s: %w", ref, err)
+	}
+	checkRuns, err := listCheckRuns(ctx, client, githubLogin, repoName, ref)
+	if err != nil {
+		return "", err
+	}
+
+	status := "none"
+	if combinedStatus.GetTotalCount() > 0 {
+		status = combinedStatus.GetState()
+	}
+	for _, checkRun := range checkRuns {
+		if checkRun.GetStatus() != "completed" {
+			if status != "failure" {
+				status = "pending"
+			}
+			continue
+		}
+		switch checkRun.GetConclusion() {
+		case "failure", "timed_out", "cancelled", "action_required", "startup_failure":
+			status = "failure"
+		default:
+			if status == "none" {
+				status = "success"
+			}
+		}
+	}
+	return status, nil
+}
+
+// Lists every check run for the ref, since large check matrices take more
+// than one page.
+func listCheckRuns(ctx context.Context, client *gh.Client, githubLogin string, repoName string, ref string) ([]*github.CheckRun, error) {
+	checkRuns := []*github.CheckRun{}
+
+	opts := &github.ListCheckRunsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		result, resp, err := client.Checks.ListCheckRunsForRef(ctx, githubLogin, repoName, ref, opts)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching check runs for %s: %w", ref, err)
+		}
+		checkRuns = append(checkRuns, result.CheckRuns...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.ListOptions.Page = resp.NextPage
+	}
+
+	return checkRuns, nil
+}
+
+func listDependabotPulls(ctx context.Context, client *gh.Client, githubLogin string, repoName string) ([]*dependabotPull, error) {
+	pulls := []*dependabotPull{}
+
+	opts := &github.PullRequestListOptions{
+		State:       "open",
+		Sort:        "created",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for {
+		prs, resp, err := client.PullRequests.List(ctx, githubLogin, repoName, opts)
+		if err != nil {
+			return nil, fmt.Errorf("error listing pull requests: %w", err)
+		}
+
+		for _, pr := range prs {
+			if pr.GetUser().GetLogin() != dependabotLogin {
+				continue
+			}
+			dependency, directory := dependabotDependency(pr)
+			pulls = append(pulls, &dependabotPull{
+				PullRequest: pr,
+				Ecosystem:   dependabotEcosystem(pr),
+				Dependency:  dependency,
+				Directory:   directory,
+			})
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.ListOptions.Page = resp.NextPage
+	}
+
+	return pulls, nil
+}
+
+// Marks PRs which update the same dependency in the same directory as a newer
+// PR as superseded by that newer PR.
+func markSupersededPulls(pulls []*dependabotPull) {
+	newest := map[string]*dependabotPull{}
+	for _, pull := range pulls {
+		if pull.Dependency == "" {
+			continue
+		}
+		key := pull.Ecosystem + ":" + pull.Directory + ":" + pull.Dependency
+		if current, ok := newest[key]; !ok || pull.GetNumber() > current.GetNumber() {
+			newest[key] = pull
+		}
+	}
+	for _, pull := range pulls {
+		if pull.Dependency == "" {
+			continue
+		}
+		key := pull.Ecosystem + ":" + pull.Directory + ":" + pull.Dependency
+		if newer := newest[key]; newer.GetNumber() != pull.GetNumber() {
+			pull.SupersededBy = newer.GetNumber()
+		}
+	}
+}
+
+func (p dependabotPull) String() string {
+	flags := []string{}
+	if p.Stale() {
+		flags = append(flags, "stale")
+	}
+	if p.SupersededBy != 0 {
+		flags = append(flags, fmt.Sprintf("superseded by #%d", p.SupersededBy))
+	}
+	description := fmt.Sprintf("#%d %s (%s) open %dd, checks: %s, mergeable: %s",
+		p.GetNumber(), p.GetTitle(), p.Ecosystem,
+		int(p.Age().Hours()/24), p.CheckStatus, p.MergeableState)
+	if len(flags) > 0 {
+		description += " [" + strings.Join(flags, ", ") + "]"
+	}
+	return description
+}
+
+func commentOnPull(ctx context.Context, client *gh.Client, githubLogin string, repoName string, number int, body string) error {
+	_, _, err := client.Issues.CreateComment(ctx, githubLogin, repoName, number, &github.IssueComment{
+		Body: github.String(body),
+	})
+	return err
+}
+
+// Closes a superseded PR, leaving a note pointing at the PR which replaces it.
+func closeSupersededPull(ctx context.Context, client *gh.Client, githubLogin string, repoName string, pull *dependabotPull) error {
+	if err := commentOnPull(ctx, client, githubLogin, repoName, pull.GetNumber(), fmt.Sprintf("Superseded by #%d.", pull.SupersededBy)); err != nil {
+		return err
+	}
+	_, _, err := client.PullRequests.Edit(ctx, githubLogin, repoName, pull.GetNumber(), &github.PullRequest{
+		State: github.String("closed"),
+	})
+	return err
+}
+
+func dependabotPullsForSingleRepo(ctx context.Context, client *gh.Client, githubLogin string, repoName string, report *repoReport) {
+	pulls, err := listDependabotPulls(ctx, client, githubLogin, repoName)
+	if err != nil {
+		report.Fail("%w", err)
+		return
+	}
+	if len(pulls) == 0 {
+		if verbose {
+			log.Printf("[%s/%s]: no open dependabot pull requests", githubLogin, repoName)
+		}
+		return
+	}
+	markSupersededPulls(pulls)
+	sort.Slice(pulls, func(i, j int) bool { return pulls[i].GetNumber() < pulls[j].GetNumber() })
+
+	for _, pull := range pulls {
+		// The list endpoint doesn't compute mergeability, so fetch each PR.
+		fullPull, _, err := client.PullRequests.Get(ctx, githubLogin, repoName, pull.GetNumber())
+		if err != nil {
+			report.Fail("error fetching #%d: %w", pull.GetNumber(), err)
+			pull.MergeableState = "unknown"
+		} else {
+			pull.MergeableState = fullPull.GetMergeableState()
+		}
+
+		pull.CheckStatus, err = checkStatus(ctx, client, githubLogin, repoName, pull.GetHead().GetSHA())
+		if err != nil {
+			report.Fail("#%d: %w", pull.GetNumber(), err)
+			pull.CheckStatus = "unknown"
+		}
+
+		report.Add("%s", pull)
+
+		switch {
+		case pull.SupersededBy != 0 && closeSupersededPulls:
+			if err := closeSupersededPull(ctx, client, githubLogin, repoName, pull); err != nil {
+				report.Fail("error closing #%d: %w", pull.GetNumber(), err)
+			} else {
+				report.Add("closed #%d", pull.GetNumber())
+			}
+		case pull.SupersededBy == 0 && rebaseDependabotPulls && needsRebase(pull):
+			if err := commentOnPull(ctx, client, githubLogin, repoName, pull.GetNumber(), "@dependabot rebase"); err != nil {
+				report.Fail("error requesting rebase of #%d: %w", pull.GetNumber(), err)
+			} else {
+				report.Add("requested rebase of #%d", pull.GetNumber())
+			}
+		}
+	}
+}
+
+// A rebase is worth asking for when the PR conflicts with or is behind its
+// base branch, or when its checks fail and a fresh run might fix them.
+func needsRebase(pull *dependabotPull) bool {
+	return pull.MergeableState == "dirty" ||
+		pull.MergeableState == "behind" ||
+		pull.CheckStatus == "failure"
+}
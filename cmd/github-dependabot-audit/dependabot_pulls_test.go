@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/parkr/github-utils/gh/ghtest"
+)
+
+func TestCheckStatusReadsEveryPage(t *testing.T) {
+	client := ghtest.NewClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/parkr/hello/commits/abc/status":
+			w.Write([]byte(`{"state": "success", "total_count": 1}`))
+		case "/repos/parkr/hello/commits/abc/check-runs":
+			if r.URL.Query().Get("page") == "2" {
+				w.Write([]byte(`{"total_count": 2, "check_runs": [{"status": "completed", "conclusion": "failure"}]}`))
+				return
+			}
+			w.Header().Set("Link", fmt.Sprintf(`<http://%s%s?page=2>; rel="next"`, r.Host, r.URL.Path))
+			w.Write([]byte(`{"total_count": 2, "check_runs": [{"status": "completed", "conclusion": "success"}]}`))
+		default:
+			http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+		}
+	})
+
+	status, err := checkStatus(context.Background(), client, "parkr", "hello", "abc")
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	if status != "failure" {
+		t.Errorf("expected the failing check run on the second page to count, got %q", status)
+	}
+}
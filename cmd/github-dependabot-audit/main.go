@@ -1,5 +1,6 @@
 // A command to process one or all of a user's repos to see if Dependabot has
-// been setup and that it covers all ecosystems in the repository.
+// been setup and that it covers all ecosystems in the repository, and to report
+// on the state of its security alerts and updates.
 package main
 
 import (
@@ -7,6 +8,9 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/google/go-github/v88/github"
@@ -14,8 +18,10 @@ import (
 )
 
 var verbose bool = false
+var enableSecuritySettings bool = false
+var auditPulls bool = false
 
-func listAllRepos(ctx context.Context, client *gh.Client, githubLogin string, repoChan chan string, done chan bool) {
+func listAllRepos(ctx context.Context, client *gh.Client, githubLogin string) []string {
 	// If org, use one method. If user, use another.
 	githubUser, _, err := client.Users.Get(ctx, githubLogin)
 	if err != nil {
@@ -42,6 +48,7 @@ func listAllRepos(ctx context.Context, client *gh.Client, githubLogin string, re
 
 	log.Println("listing repos for", githubLogin)
 
+	repoNames := []string{}
 	opt := &github.ListOptions{PerPage: 100}
 	for {
 		repos, resp, err := listMethod(ctx, githubLogin, opt)
@@ -69,73 +76,69 @@ func listAllRepos(ctx context.Context, client *gh.Client, githubLogin string, re
 				}
 				continue
 			}
-			// log.Printf("[%s] enqueueing", repo.GetFullName())
-			repoChan <- repo.GetName()
+			repoNames = append(repoNames, repo.GetName())
 		}
 		if resp.NextPage == 0 {
 			break
 		}
 		opt.Page = resp.NextPage
 	}
-	close(repoChan)
-	done <- true
+	return repoNames
 }
 
-func dependabotAuditForSingleRepo(ctx context.Context, client *gh.Client, githubLogin string, repoName string) {
+// Files whose presence indicates a Dependabot ecosystem should be configured.
+var ecosystemDetectors = []struct {
+	ecosystem string
+	detect    func(*repoFiles) bool
+}{
+	{"gomod", func(f *repoFiles) bool { return f.ContainsFile("go.mod") }},
+	{"pip", func(f *repoFiles) bool { return f.ContainsFile("requirements.txt") }},
+	{"docker", func(f *repoFiles) bool { return f.ContainsFile("Dockerfile") }},
+	{"bundler", func(f *repoFiles) bool { return f.ContainsFile("Gemfile") }},
+	{"github-actions", func(f *repoFiles) bool { return f.ContainsFilesIn(".github/workflows") }},
+	{"npm", func(f *repoFiles) bool { return f.ContainsFile("package.json") }},
+	{"cargo", func(f *repoFiles) bool { return f.ContainsFile("Cargo.toml") }},
+}
+
+func dependabotAuditForSingleRepo(ctx context.Context, client *gh.Client, githubLogin string, repoName string, report *repoReport) {
+	files := fetchRepoFiles(ctx, client, githubLogin, repoName)
+
 	// What dependabot ecosystems should be declared?
 	checkedEcosystems := map[string]bool{}
-	// 1. Go: go.mod file
-	checkedEcosystems["gomod"] = containsFile(ctx, client, githubLogin, repoName, "go.mod")
-	// 2. Python: requirements.txt file
-	checkedEcosystems["pip"] = containsFile(ctx, client, githubLogin, repoName, "requirements.txt")
-	// 3. Docker: Dockerfile
-	checkedEcosystems["docker"] = containsFile(ctx, client, githubLogin, repoName, "Dockerfile")
-	// 4. Ruby: Gemfile
-	checkedEcosystems["bundler"] = containsFile(ctx, client, githubLogin, repoName, "Gemfile")
-	// 5. Actions: .github/workflows directory
-	checkedEcosystems["github-actions"] = containsGitHubActions(ctx, client, githubLogin, repoName)
-	// 6. Node: package.json file
-	checkedEcosystems["npm"] = containsFile(ctx, client, githubLogin, repoName, "package.json")
-	// 7. Rust: Cargofile
-	checkedEcosystems["cargo"] = containsFile(ctx, client, githubLogin, repoName, "Cargo.toml")
-	// log.Printf("[%s/%s]: checked ecosystems: %#v", githubLogin, repoName, checkedEcosystems)
+	for _, detector := range ecosystemDetectors {
+		if detector.detect(files) {
+			checkedEcosystems[detector.ecosystem] = true
+		}
+	}
+
+	// Alerts and security updates matter whatever the repo contains.
+	securityAuditForSingleRepo(ctx, client, githubLogin, repoName, report)
 
 	// No ecosystems? Quickly return.
 	if len(checkedEcosystems) == 0 {
-		log.Printf("[%s/%s]: no supported updateable files found", githubLogin, repoName)
+		report.Add("no supported updateable files found")
 		return
 	}
 
-	// Read ecosystems that are currently configured.
+	// Read ecosystems that are currently configured, by either Dependabot or Renovate.
 	currentlyConfiguredEcosystems := map[string]bool{}
-	dependabotFile := readDependabotConfig(ctx, client, githubLogin, repoName)
+	dependabotFile, usesDependabot := readDependabotConfig(files)
 	for _, dependabotUpdateConfig := range dependabotFile.Updates {
 		currentlyConfiguredEcosystems[dependabotUpdateConfig.PackageEcosystem] = true
 	}
+	renovateFile, usesRenovate := readRenovateConfig(files)
+	if usesRenovate {
+		for ecosystem := range renovateFile.Ecosystems() {
+			currentlyConfiguredEcosystems[ecosystem] = true
+		}
+	}
+	report.Add("updater: %s", updaterNames(usesDependabot, usesRenovate))
 	// log.Printf("[%s/%s]: currently configured: %#v", githubLogin, repoName, currentlyConfiguredEcosystems)
 
 	// Compare what should be declared and what is declared.
 	for ecosystem, isRequired := range checkedEcosystems {
 		if isRequired && !currentlyConfiguredEcosystems[ecosystem] {
-			log.Printf("[%s/%s]: missing ecosystem: %s", githubLogin, repoName, ecosystem)
-		}
-	}
-}
-
-func dependabotAuditForRepos(ctx context.Context, client *gh.Client, githubLogin string, repoChan chan string, done chan bool) {
-	for {
-		select {
-		case <-ctx.Done():
-			if ctx.Err() != nil {
-				log.Fatalln(ctx.Err())
-			}
-			return
-		case repoName, more := <-repoChan:
-			if !more {
-				done <- true
-				return
-			}
-			dependabotAuditForSingleRepo(ctx, client, githubLogin, repoName)
+			report.Add("missing ecosystem: %s", ecosystem)
 		}
 	}
 }
@@ -144,38 +147,51 @@ func main() {
 	githubLogin := flag.String("login", "", "GitHub Login (user or org) whose repos to list (default: currently-authorized user)")
 	singleRepo := flag.String("repo", "", "Single repo to audit (default: audit all repos for the login")
 	flag.BoolVar(&verbose, "verbose", false, "Enable verbose logging")
+	concurrency := 3
+	flag.IntVar(&concurrency, "concurrency", concurrency, "Number of repos to audit at once")
+	repoTimeout := 2 * time.Minute
+	flag.DurationVar(&repoTimeout, "timeout", repoTimeout, "Maximum time to spend auditing each repo")
+	flag.BoolVar(&enableSecuritySettings, "enable-security-updates", false, "Enable vulnerability alerts and automated security fixes where they're disabled")
+	flag.BoolVar(&auditPulls, "pulls", false, "Report on open Dependabot pull requests instead of configuration")
+	flag.DurationVar(&staleAfter, "stale-after", staleAfter, "With -pulls, how long a pull request may be open before it's considered stale")
+	flag.BoolVar(&rebaseDependabotPulls, "rebase", false, "With -pulls, comment '@dependabot rebase' on pull requests which conflict, are behind, or fail checks")
+	flag.BoolVar(&closeSupersededPulls, "close-superseded", false, "With -pulls, close pull requests superseded by a newer update of the same dependency")
 	flag.Parse()
 
 	if githubLogin == nil || *githubLogin == "" {
 		log.Fatalln("fatal: -login flag required")
 	}
 
+	if concurrency < 1 {
+		log.Fatalln("fatal: -concurrency must be at least 1")
+	}
+
 	client, err := gh.NewDefaultClient()
 	if err != nil {
 		log.Fatalf("fatal: could not initialize client: %v", err)
 	}
 
-	ctx, cancel := context.WithTimeout(client.Context, 5*time.Minute)
-	defer cancel()
-
-	done := make(chan bool, 2)
-	repoChan := make(chan string, 10)
-
-	go dependabotAuditForRepos(ctx, client, *githubLogin, repoChan, done)
-	go dependabotAuditForRepos(ctx, client, *githubLogin, repoChan, done)
-	go dependabotAuditForRepos(ctx, client, *githubLogin, repoChan, done)
+	// Stop starting new repos on interrupt, but report on the ones we finished.
+	ctx, stop := signal.NotifyContext(client.Context, os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
+	var repoNames []string
 	if singleRepo != nil && *singleRepo != "" {
-		repoChan <- *singleRepo
-		close(repoChan)
-		done <- true
+		repoNames = []string{*singleRepo}
 	} else {
-		listAllRepos(ctx, client, *githubLogin, repoChan, done)
+		repoNames = listAllRepos(ctx, client, *githubLogin)
+	}
+
+	audit := auditFunc(dependabotAuditForSingleRepo)
+	if auditPulls {
+		audit = dependabotPullsForSingleRepo
 	}
+	reports := auditRepos(ctx, client, *githubLogin, repoNames, audit, concurrency, repoTimeout)
+	printSummary(reports, *githubLogin, repoNames)
 
-	<-done // listAllRepos
-	<-done // actionsAuditForRepos
-	<-done // actionsAuditForRepos 2
-	<-done // actionsAuditForRepos 3
-	log.Println("audit complete")
+	if ctx.Err() != nil {
+		log.Println("audit interrupted")
+	} else {
+		log.Println("audit complete")
+	}
 }
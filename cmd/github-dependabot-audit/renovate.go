@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+)
+
+// Locations Renovate reads its configuration from, in the order it checks them.
+// https://docs.renovatebot.com/configuration-options/
+var renovateConfigFiles = []string{
+	"renovate.json",
+	"renovate.json5",
+	".github/renovate.json",
+	".github/renovate.json5",
+	".gitlab/renovate.json",
+	".gitlab/renovate.json5",
+	".renovaterc",
+	".renovaterc.json",
+	".renovaterc.json5",
+}
+
+// Maps Renovate manager names to the Dependabot ecosystem they cover.
+var renovateManagerEcosystems = map[string]string{
+	"gomod":            "gomod",
+	"pip_requirements": "pip",
+	"dockerfile":       "docker",
+	"bundler":          "bundler",
+	"github-actions":   "github-actions",
+	"npm":              "npm",
+	"cargo":            "cargo",
+}
+
+type renovateManagerConfig struct {
+	Enabled *bool `json:"enabled"`
+}
+
+type renovateConfig struct {
+	EnabledManagers []string
+	Managers        map[string]renovateManagerConfig
+}
+
+func (c *renovateConfig) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if enabledManagers, ok := raw["enabledManagers"]; ok {
+		if err := json.Unmarshal(enabledManagers, &c.EnabledManagers); err != nil {
+			return err
+		}
+	}
+	c.Managers = map[string]renovateManagerConfig{}
+	for manager := range renovateManagerEcosystems {
+		managerConfig, ok := raw[manager]
+		if !ok {
+			continue
+		}
+		var parsed renovateManagerConfig
+		if err := json.Unmarshal(managerConfig, &parsed); err != nil {
+			return err
+		}
+		c.Managers[manager] = parsed
+	}
+	return nil
+}
+
+// Returns the Dependabot ecosystems covered by this Renovate configuration.
+// Renovate enables every manager by default, so unless the config restricts
+// them with enabledManagers or disables one explicitly, they're all covered.
+func (c renovateConfig) Ecosystems() map[string]bool {
+	enabledManagers := map[string]bool{}
+	if len(c.EnabledManagers) > 0 {
+		for _, manager := range c.EnabledManagers {
+			enabledManagers[manager] = true
+		}
+	} else {
+		for manager := range renovateManagerEcosystems {
+			enabledManagers[manager] = true
+		}
+	}
+
+	ecosystems := map[string]bool{}
+	for manager, ecosystem := range renovateManagerEcosystems {
+		if !enabledManagers[manager] {
+			continue
+		}
+		if managerConfig, ok := c.Managers[manager]; ok && managerConfig.Enabled != nil && !*managerConfig.Enabled {
+			continue
+		}
+		ecosystems[ecosystem] = true
+	}
+	return ecosystems
+}
+
+// Parses a Renovate configuration file. JSON5 files are handled on a
+// best-effort basis by stripping comments and trailing commas.
+func parseRenovateConfig(content string) (renovateConfig, error) {
+	config := renovateConfig{}
+	err := json.Unmarshal([]byte(stripJSON5(content)), &config)
+	return config, err
+}
+
+// Removes the comments and trailing commas JSON5 allows but JSON doesn't,
+// and turns its single-quoted strings into double-quoted ones, leaving the
+// contents of strings, like globs with "/*" in them, untouched.
+func stripJSON5(content string) string {
+	stripped := []byte{}
+	// Where the last comma is in stripped, until something other than
+	// whitespace or a comment follows it.
+	comma := -1
+	for i := 0; i < len(content); i++ {
+		switch c := content[i]; {
+		case c == '"':
+			end := i + 1
+			for end < len(content) && content[end] != c {
+				if content[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end = min(end+1, len(content))
+			stripped = append(stripped, content[i:end]...)
+			comma = -1
+			i = end - 1
+		case c == '\'':
+			// JSON only has double-quoted strings, so single quotes no
+			// longer need escaping inside them, but double quotes do.
+			stripped = append(stripped, '"')
+			for i++; i < len(content) && content[i] != '\''; i++ {
+				switch {
+				case content[i] == '\\' && i+1 < len(content) && content[i+1] == '\'':
+					stripped = append(stripped, '\'')
+					i++
+				case content[i] == '\\' && i+1 < len(content):
+					stripped = append(stripped, content[i], content[i+1])
+					i++
+				case content[i] == '"':
+					stripped = append(stripped, '\\', '"')
+				default:
+					stripped = append(stripped, content[i])
+				}
+			}
+			stripped = append(stripped, '"')
+			comma = -1
+		case strings.HasPrefix(content[i:], "//"):
+			end := strings.IndexByte(content[i:], '\n')
+			if end == -1 {
+				end = len(content) - i
+			}
+			i += end - 1
+		case strings.HasPrefix(content[i:], "/*"):
+			end := strings.Index(content[i+2:], "*/")
+			if end == -1 {
+				end = len(content) - i - 2
+			}
+			i += end + 3
+		case c == '}' || c == ']':
+			if comma != -1 {
+				stripped = append(stripped[:comma], stripped[comma+1:]...)
+			}
+			stripped = append(stripped, c)
+			comma = -1
+		case c == ',':
+			comma = len(stripped)
+			stripped = append(stripped, c)
+		default:
+			if !strings.ContainsRune(" \t\r\n", rune(c)) {
+				comma = -1
+			}
+			stripped = append(stripped, c)
+		}
+	}
+	return string(stripped)
+}
+
+// Looks for Renovate configuration in the repo and returns it, along with
+// whether it was found at all.
+func readRenovateConfig(files *repoFiles) (renovateConfig, bool) {
+	for _, path := range renovateConfigFiles {
+		content, ok := files.ReadFile(path)
+		if !ok {
+			continue
+		}
+		config, err := parseRenovateConfig(content)
+		if err != nil {
+			// Renovate is configured, we just can't tell how. Assume the defaults.
+			log.Printf("[%s/%s]: unable to parse %s, assuming default managers: %v", files.githubLogin, files.repoName, path, err)
+			return renovateConfig{}, true
+		}
+		return config, true
+	}
+
+	// Renovate also reads a "renovate" key from package.json.
+	if content, ok := files.ReadFile("package.json"); ok {
+		packageJSON := struct {
+			Renovate *renovateConfig `json:"renovate"`
+		}{}
+		if err := json.Unmarshal([]byte(content), &packageJSON); err != nil {
+			if verbose {
+				log.Printf("[%s/%s]: unable to parse package.json: %v", files.githubLogin, files.repoName, err)
+			}
+		} else if packageJSON.Renovate != nil {
+			return *packageJSON.Renovate, true
+		}
+	}
+
+	return renovateConfig{}, false
+}
+
+// Returns a human-readable description of the dependency updaters in use.
+func updaterNames(usesDependabot, usesRenovate bool) string {
+	updaters := []string{}
+	if usesDependabot {
+		updaters = append(updaters, "dependabot")
+	}
+	if usesRenovate {
+		updaters = append(updaters, "renovate")
+	}
+	if len(updaters) == 0 {
+		return "none"
+	}
+	return strings.Join(updaters, ", ")
+}
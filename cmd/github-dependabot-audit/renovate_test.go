@@ -0,0 +1,101 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRenovateConfig(t *testing.T) {
+	allEcosystems := map[string]bool{}
+	for _, ecosystem := range renovateManagerEcosystems {
+		allEcosystems[ecosystem] = true
+	}
+
+	testCases := []struct {
+		name     string
+		content  string
+		expected map[string]bool
+	}{
+		{
+			name:     "defaults",
+			content:  `{"extends": ["config:recommended"]}`,
+			expected: allEcosystems,
+		},
+		{
+			name:     "enabled managers",
+			content:  `{"enabledManagers": ["gomod", "dockerfile", "unknown"]}`,
+			expected: map[string]bool{"gomod": true, "docker": true},
+		},
+		{
+			name:     "disabled manager",
+			content:  `{"enabledManagers": ["gomod", "npm"], "npm": {"enabled": false}}`,
+			expected: map[string]bool{"gomod": true},
+		},
+		{
+			name: "json5 comments and trailing commas",
+			content: `{
+  // Only Go and Actions.
+  "enabledManagers": [
+    "gomod", // Modules
+    "github-actions", /* Workflows */
+  ],
+  /* Not
+     npm */
+  "npm": {"enabled": false,},
+}`,
+			expected: map[string]bool{"gomod": true, "github-actions": true},
+		},
+		{
+			name: "comment markers in strings",
+			content: `{
+  "enabledManagers": ["npm"], // Only npm
+  "ignorePaths": ["src/**/*.js", "http://example.com//path", "quote \" /* not a comment */"],
+}`,
+			expected: map[string]bool{"npm": true},
+		},
+		{
+			name: "json5 single-quoted strings",
+			content: `{
+  'enabledManagers': ['gomod', 'npm'],
+}`,
+			expected: map[string]bool{"gomod": true, "npm": true},
+		},
+		{
+			name:     "json5 single-quoted strings with quotes",
+			content:  `{'enabledManagers': ['gomod', 'npm'], 'description': 'Parker\'s "config"', 'npm': {'enabled': false}}`,
+			expected: map[string]bool{"gomod": true},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			config, err := parseRenovateConfig(testCase.content)
+			if err != nil {
+				t.Fatalf("unexpected error: %+v", err)
+			}
+			if actual := config.Ecosystems(); !reflect.DeepEqual(actual, testCase.expected) {
+				t.Errorf("expected ecosystems %v, got %v", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestStripJSON5(t *testing.T) {
+	testCases := []struct {
+		content, expected string
+	}{
+		{`{"a": 1}`, `{"a": 1}`},
+		{`{"a": [1, 2,], }`, `{"a": [1, 2] }`},
+		{"{\"a\": 1, // trailing\n}", "{\"a\": 1 \n}"},
+		{`{"a": "src/**/*.js"}`, `{"a": "src/**/*.js"}`},
+		{`{"a": "x, ]"}`, `{"a": "x, ]"}`},
+		{`{"a": /* unterminated`, `{"a": `},
+		{`{'a': 'it\'s "quoted" // here'}`, `{"a": "it's \"quoted\" // here"}`},
+		{`{"a": 'x\ny'}`, `{"a": "x\ny"}`},
+	}
+	for _, testCase := range testCases {
+		if actual := stripJSON5(testCase.content); actual != testCase.expected {
+			t.Errorf("stripJSON5(%q): expected %q, got %q", testCase.content, testCase.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/parkr/github-utils/gh"
+)
+
+// The findings of an audit of a single repo. Findings are logged as they're
+// added so progress is visible, and kept so they can be summarized at the end.
+type repoReport struct {
+	Name     string
+	Findings []string
+	Err      error
+}
+
+func (r *repoReport) Add(format string, args ...interface{}) {
+	finding := fmt.Sprintf(format, args...)
+	r.Findings = append(r.Findings, finding)
+	log.Printf("[%s]: %s", r.Name, finding)
+}
+
+// Records an error which leaves the audit of the repo incomplete, so it's
+// counted in the summary, not just logged.
+func (r *repoReport) Fail(format string, args ...interface{}) {
+	err := fmt.Errorf(format, args...)
+	if r.Err == nil {
+		r.Err = err
+	} else {
+		r.Err = fmt.Errorf("%w; %w", r.Err, err)
+	}
+	log.Printf("[%s]: %v", r.Name, err)
+}
+
+type auditFunc func(ctx context.Context, client *gh.Client, githubLogin string, repoName string, report *repoReport)
+
+// Runs audit against each of repoNames using a pool of concurrency workers,
+// giving each repo up to timeout to complete. If ctx is cancelled, no new
+// repos are started and the reports for those which finished are returned.
+func auditRepos(ctx context.Context, client *gh.Client, githubLogin string, repoNames []string, audit auditFunc, concurrency int, timeout time.Duration) []*repoReport {
+	jobs := make(chan string)
+	results := make(chan *repoReport)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for repoName := range jobs {
+				results <- auditRepo(ctx, client, githubLogin, repoName, audit, timeout)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for _, repoName := range repoNames {
+			select {
+			case jobs <- repoName:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	reports := []*repoReport{}
+	for report := range results {
+		reports = append(reports, report)
+		status := fmt.Sprintf("%d findings", len(report.Findings))
+		if report.Err != nil {
+			status = report.Err.Error()
+		}
+		log.Printf("[%d/%d] %s: %s", len(reports), len(repoNames), report.Name, status)
+	}
+	return reports
+}
+
+func auditRepo(ctx context.Context, client *gh.Client, githubLogin string, repoName string, audit auditFunc, timeout time.Duration) *repoReport {
+	repoCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	report := &repoReport{Name: githubLogin + "/" + repoName}
+	audit(repoCtx, client, githubLogin, repoName, report)
+
+	switch {
+	case errors.Is(repoCtx.Err(), context.DeadlineExceeded):
+		report.Fail("timed out after %s, findings may be incomplete", timeout)
+	case ctx.Err() != nil:
+		report.Fail("interrupted, findings may be incomplete")
+	}
+	return report
+}
+
+// Prints every finding, grouped by repo, along with any repos which weren't
+// audited because the run was interrupted.
+func printSummary(reports []*repoReport, githubLogin string, repoNames []string) {
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Name < reports[j].Name })
+
+	audited := map[string]bool{}
+	failed := 0
+	fmt.Println("Summary:")
+	for _, report := range reports {
+		audited[report.Name] = true
+		if report.Err != nil {
+			failed++
+		}
+		if len(report.Findings) == 0 && report.Err == nil {
+			continue
+		}
+		fmt.Printf("\n%s\n", report.Name)
+		for _, finding := range report.Findings {
+			fmt.Printf("  - %s\n", finding)
+		}
+		if report.Err != nil {
+			fmt.Printf("  ! %v\n", report.Err)
+		}
+	}
+
+	skipped := []string{}
+	for _, repoName := range repoNames {
+		if !audited[githubLogin+"/"+repoName] {
+			skipped = append(skipped, repoName)
+		}
+	}
+
+	fmt.Printf("\nAudited %d/%d repos", len(reports), len(repoNames))
+	if failed > 0 {
+		fmt.Printf(", %d incomplete", failed)
+	}
+	fmt.Println()
+	if len(skipped) > 0 {
+		fmt.Printf("Not audited: %s\n", strings.Join(skipped, ", "))
+	}
+}
@@ -1,10 +1,18 @@
-// A command-line utility to download pull requests for offline reading.
+// A command-line utility to download pull requests for offline reading, to
+// search what was downloaded, and to queue replies to them which are posted
+// once back online.
 package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/google/go-github/v88/github"
 	"github.com/parkr/github-utils/gh"
@@ -12,9 +20,10 @@ import (
 )
 
 // 1. Read in pull requests from the API. Push into queue. Close queue when finished.
-// 2. Read from queue, and batch process 10 pull requests at once:
-//     2a. Write a `.patch` file for the pull request.
-//     2b. Write a metadata file, preferably in Markdown or something human-readable.
+// 2. Read from queue, and process -concurrency pull requests at once:
+//     2a. Write a `.patch` file for the pull request, and optionally snapshot the files it touches.
+//     2b. Write the comments as an mbox or Maildir, so they can be read in a mail client,
+//         or as a Markdown or HTML page.
 //           - Username of author
 //           - PR reviews & comments
 //           - PR comment chain
@@ -26,37 +35,98 @@ func writeOutputDirectory(dir string) error {
 	return nil
 }
 
-func batchPullRequests(input chan *github.PullRequest, bridge chan []*github.PullRequest) {
-	counter := 0
-	prs := []*github.PullRequest{}
-	for pr := range input {
-		counter++
-		prs = append(prs, pr)
-		if counter%5 == 0 {
-			bridge <- prs
-			prs = []*github.PullRequest{}
-		}
-	}
-	if len(prs) > 0 {
-		bridge <- prs
-	}
-	close(bridge)
-}
-
 func main() {
 	cwd, err := os.Getwd()
 	if err != nil {
 		log.Fatalf("fatal: could not get $CWD: %+v", err)
 	}
 
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "reply":
+			reply(cwd, os.Args[2:])
+			return
+		case "push":
+			push(cwd, os.Args[2:])
+			return
+		case "index":
+			index(cwd, os.Args[2:])
+			return
+		case "query":
+			query(cwd, os.Args[2:])
+			return
+		}
+	}
+
 	var repo string
-	flag.StringVar(&repo, "repo", "", "The repository NWO (e.g. parkr/auto-reply) to copy locally.")
+	flag.StringVar(&repo, "repo", "", "The repository NWO (e.g. parkr/auto-reply) to copy locally. Separate several with commas.")
+	var org string
+	flag.StringVar(&org, "org", "", "Copy every unarchived repository in this organization locally.")
 	var dir string
 	flag.StringVar(&dir, "dir", cwd, "Output directory, defaults to $CWD.")
+	formats := append(append([]string{}, pulls.MessageFormats...), pulls.PageFormats...)
+	var format string
+	flag.StringVar(&format, "format", "mbox", "Format to write comments in, as messages or pages: "+strings.Join(formats, ", "))
+	var full bool
+	flag.BoolVar(&full, "full", false, "Download every pull request, even if it hasn't changed since the last run.")
+	var diff bool
+	flag.BoolVar(&diff, "diff", false, "Download each pull request as a .diff instead of a .patch.")
+	var maxPatchSize int64
+	flag.Int64Var(&maxPatchSize, "max-patch-size", pulls.DefaultMaxPatchSize, "The largest patch to download, in bytes.")
+	var snapshots bool
+	flag.BoolVar(&snapshots, "snapshots", false, "Also download the full contents of the files each pull request touches, as of its head commit.")
+	var concurrency int
+	flag.IntVar(&concurrency, "concurrency", pulls.DefaultConcurrency, "How many pull requests or issues to download at once.")
+	var issues bool
+	flag.BoolVar(&issues, "issues", false, "Download issues instead of pull requests.")
+	var state string
+	flag.StringVar(&state, "state", "open", "The state of pull requests (open, closed, merged or all) or issues (open, closed or all) to download.")
+	var labels string
+	flag.StringVar(&labels, "label", "", "Only download pull requests or issues with all of these comma-separated labels.")
+	var base string
+	flag.StringVar(&base, "base", "", "Only download pull requests into this branch.")
+	var author string
+	flag.StringVar(&author, "author", "", "Only download pull requests opened by this user.")
+	var updatedSince string
+	flag.StringVar(&updatedSince, "updated-since", "", "Only download pull requests updated since this date, e.g. 2006-01-02.")
+	var maxCount int
+	flag.IntVar(&maxCount, "max", 0, "Download at most this many pull requests per repository, most recently updated first.")
+	var assignee string
+	flag.StringVar(&assignee, "assignee", "", "With -issues, only download issues assigned to this user.")
+	var query string
+	flag.StringVar(&query, "query", "", "With -issues, only download issues matching this search query, e.g. 'is:open label:bug'.")
 	flag.Parse()
 
-	if repo == "" {
-		log.Fatalln("fatal: missing -repo")
+	if repo == "" && org == "" {
+		log.Fatalln("fatal: missing -repo or -org")
+	}
+	if concurrency < 1 {
+		log.Fatalln("fatal: -concurrency must be at least 1")
+	}
+	if issues && state == "merged" {
+		log.Fatalln("fatal: -state merged only applies to pull requests")
+	}
+
+	filter := pulls.PullRequestFilter{State: state, Base: base, Author: author, Max: maxCount}
+	if labels != "" {
+		filter.Labels = strings.Split(labels, ",")
+	}
+	if updatedSince != "" {
+		if filter.UpdatedSince, err = time.Parse("2006-01-02", updatedSince); err != nil {
+			log.Fatalf("fatal: invalid -updated-since %q: %v", updatedSince, err)
+		}
+	}
+
+	// Messages can be read in a mail client, and pages in a browser or editor.
+	var writer pulls.MessageWriter
+	var renderer *pulls.PageRenderer
+	if renderer, err = pulls.NewPageRenderer(format); err != nil {
+		if writer, err = pulls.NewMessageWriter(format); err != nil {
+			log.Fatalf("fatal: unknown -format %q, expected one of: %s", format, strings.Join(formats, ", "))
+		}
+	}
+	if issues && writer == nil {
+		log.Fatalf("fatal: -issues can only be written as %s", strings.Join(pulls.MessageFormats, " or "))
 	}
 
 	client, err := gh.NewDefaultClient()
@@ -64,23 +134,150 @@ func main() {
 		log.Fatalf("fatal: could not initialize client: %v", err)
 	}
 
-	if err = writeOutputDirectory(dir); err != nil {
-		log.Fatalf("fatal: could not write output directory %s: %+v", dir, err)
+	// Stop starting new pull requests on interrupt, but save what was written.
+	ctx, stop := signal.NotifyContext(client.Context, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	client.Context = ctx
+
+	repos := []string{}
+	if repo != "" {
+		repos = append(repos, strings.Split(repo, ",")...)
+	}
+	if org != "" {
+		orgRepos, err := listOrgRepos(client, org)
+		if err != nil {
+			log.Fatalf("fatal: could not list repos for %s: %+v", org, err)
+		}
+		repos = append(repos, orgRepos...)
 	}
 
-	input := make(chan *github.PullRequest, 100)
-	bridge := make(chan []*github.PullRequest)
-	output := make(chan pulls.OfflineStatusResponse)
+	failed := 0
+	for _, repo := range repos {
+		if ctx.Err() != nil {
+			break
+		}
+
+		// With several repos, each gets its own directory so their numbers
+		// don't collide.
+		repoDir := dir
+		if len(repos) > 1 {
+			repoDir = filepath.Join(dir, filepath.FromSlash(repo))
+		}
+		if err = writeOutputDirectory(repoDir); err != nil {
+			log.Fatalf("fatal: could not write output directory %s: %+v", repoDir, err)
+		}
+
+		if issues {
+			issueFilter := pulls.IssueFilter{State: state, Labels: filter.Labels, Assignee: assignee, Query: query}
+			opts := pulls.Options{OutputDir: repoDir, Writer: writer, Concurrency: concurrency}
+			if err := archiveIssues(client, opts, repo, issueFilter); err != nil {
+				log.Printf("Archiving issues of %s failed: %+v", repo, err)
+				failed++
+			}
+		} else {
+			opts := pulls.Options{
+				OutputDir:    repoDir,
+				Writer:       writer,
+				Renderer:     renderer,
+				PatchType:    github.Patch,
+				MaxPatchSize: maxPatchSize,
+				Snapshots:    snapshots,
+				Concurrency:  concurrency,
+			}
+			if diff {
+				opts.PatchType = github.Diff
+			}
+			if err := syncPullRequests(client, opts, repo, filter, full); err != nil {
+				log.Printf("Syncing %s failed: %+v", repo, err)
+				failed++
+			}
+		}
+	}
 
-	go pulls.FetchPullRequests(client, repo, input)
-	go batchPullRequests(input, bridge)
-	go pulls.CachePullRequestsLocally(client, dir, repo, bridge, output)
+	if ctx.Err() != nil {
+		log.Fatalln("fatal: interrupted")
+	}
+	if failed > 0 {
+		log.Fatalf("fatal: %d of %d repos failed to sync", failed, len(repos))
+	}
+}
 
-	for resp := range output {
+// Downloads the repo's issues matching the filter into the output directory.
+// It returns an error if any couldn't be fetched or written.
+func archiveIssues(client *gh.Client, opts pulls.Options, repo string, filter pulls.IssueFilter) error {
+	summary, err := pulls.ArchiveIssues(client, opts, repo, filter, func(resp pulls.OfflineStatusResponse) {
 		if resp.Success {
 			log.Printf("Wrote %s#%d to %s", repo, resp.Number, resp.Filename)
+		} else {
+			log.Printf("Fetching issue %s#%d failed: %+v", repo, resp.Number, resp.Error)
+		}
+	})
+	log.Printf("%s: %s", repo, summary)
+
+	if err == nil && summary.Failed > 0 {
+		err = fmt.Errorf("%d issues failed", summary.Failed)
+	}
+	return err
+}
+
+// Downloads the repo's pull requests matching the filter into the output
+// directory. It returns an error if any couldn't be fetched or written.
+func syncPullRequests(client *gh.Client, opts pulls.Options, repo string, filter pulls.PullRequestFilter, full bool) error {
+	dir := opts.OutputDir
+	state, err := pulls.LoadSyncState(dir)
+	if err != nil {
+		log.Fatalf("fatal: could not read sync state from %s: %+v", dir, err)
+	}
+	state.Full = full
+	opts.State = state
+	syncStartedAt := time.Now()
+
+	seen := map[int]bool{}
+	summary, err := pulls.ArchivePullRequests(client, opts, repo, filter, func(resp pulls.OfflineStatusResponse) {
+		seen[resp.Number] = true
+		if resp.Skipped {
+			log.Printf("Skipped %s#%d, unchanged since last sync", repo, resp.Number)
+		} else if resp.Success {
+			log.Printf("Wrote %s#%d to %s", repo, resp.Number, resp.Filename)
 		} else {
 			log.Printf("Fetching PR %s#%d failed: %+v", repo, resp.Number, resp.Error)
 		}
+	})
+	log.Printf("%s: %s", repo, summary)
+
+	// Only a complete listing of open pull requests says which are no longer
+	// open. Filtered ones leave out pull requests which may still be open.
+	if err == nil && filter.ListsAllOpen() {
+		closed, err := state.MarkClosed(client, repo, seen)
+		if err != nil {
+			log.Printf("error checking for closed pull requests: %+v", err)
+		}
+		for _, pr := range closed {
+			if pr.GetMerged() {
+				log.Printf("%s#%d was merged since the last sync", repo, pr.GetNumber())
+			} else {
+				log.Printf("%s#%d was closed since the last sync", repo, pr.GetNumber())
+			}
+		}
+	}
+
+	if opts.Renderer != nil {
+		if filename, err := opts.Renderer.WriteIndex(dir, repo, state); err != nil {
+			log.Printf("error writing index for %s: %+v", repo, err)
+		} else {
+			log.Printf("Wrote index of %s to %s", repo, filename)
+		}
+	}
+
+	if err == nil {
+		state.Finish(repo, syncStartedAt)
+	}
+	if saveErr := state.Save(); saveErr != nil {
+		log.Fatalf("fatal: could not save sync state to %s: %+v", dir, saveErr)
+	}
+
+	if err == nil && summary.Failed > 0 {
+		err = fmt.Errorf("%d pull requests failed", summary.Failed)
 	}
+	return err
 }
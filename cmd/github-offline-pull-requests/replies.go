@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/parkr/github-utils/gh"
+	"github.com/parkr/github-utils/pulls"
+)
+
+// Queues a reply to a downloaded pull request, to be posted with `push`.
+func reply(cwd string, args []string) {
+	flags := flag.NewFlagSet("reply", flag.ExitOnError)
+	dir := flags.String("dir", cwd, "Output directory the pull requests were downloaded to, defaults to $CWD.")
+	repo := flags.String("repo", "", "The repository NWO (e.g. parkr/auto-reply) of the pull request.")
+	number := flags.Int("pr", 0, "The number of the pull request to reply to.")
+	comment := flags.Int64("comment", 0, "The ID of the line comment to reply to, if any.")
+	path := flags.String("path", "", "The file to comment on, for a line comment.")
+	line := flags.Int("line", 0, "The line to comment on, for a line comment.")
+	startLine := flags.Int("start-line", 0, "The first line to comment on, for a comment on several lines.")
+	side := flags.String("side", "RIGHT", "The side of the diff to comment on: LEFT or RIGHT.")
+	review := flags.String("review", "", "Submit a review instead: APPROVE, REQUEST_CHANGES or COMMENT.")
+	body := flags.String("m", "", "The body of the reply. If empty, fill it in by editing the draft.")
+	flags.Parse(args)
+
+	draft := &pulls.Draft{
+		Repo:             *repo,
+		Number:           *number,
+		InReplyToComment: *comment,
+		Path:             *path,
+		StartLine:        *startLine,
+		Line:             *line,
+		Side:             strings.ToUpper(*side),
+		ReviewEvent:      strings.ToUpper(*review),
+		Body:             *body,
+	}
+	if err := draft.Validate(); err != nil {
+		log.Fatalf("fatal: invalid reply: %v", err)
+	}
+
+	// Line comments and reviews refer to the head commit which was downloaded.
+	if draft.Kind() == pulls.DraftLineComment || draft.Kind() == pulls.DraftReview {
+		state, err := pulls.LoadSyncState(*dir)
+		if err != nil {
+			log.Fatalf("fatal: could not read sync state from %s: %+v", *dir, err)
+		}
+		if repoState := state.Repos[*repo]; repoState != nil && repoState.PullRequests[*number] != nil {
+			draft.CommitID = repoState.PullRequests[*number].HeadSHA
+		}
+	}
+
+	filename, err := pulls.SaveDraft(*dir, draft)
+	if err != nil {
+		log.Fatalf("fatal: could not save draft: %+v", err)
+	}
+	fmt.Println(filename)
+}
+
+// Posts every queued reply, moving those which succeed into drafts/sent.
+func push(cwd string, args []string) {
+	flags := flag.NewFlagSet("push", flag.ExitOnError)
+	dir := flags.String("dir", cwd, "Output directory the pull requests were downloaded to, defaults to $CWD.")
+	repo := flags.String("repo", "", "The repository NWO for drafts which don't specify one.")
+	force := flags.Bool("force", false, "Post line comments and reviews even if the pull request changed since they were drafted.")
+	dryRun := flags.Bool("dry-run", false, "List the queued replies without posting them.")
+	flags.Parse(args)
+
+	drafts, errs := pulls.LoadDrafts(*dir, *repo)
+	for _, err := range errs {
+		log.Printf("skipping draft: %v", err)
+	}
+	if len(drafts) == 0 {
+		log.Println("no drafts to push")
+		return
+	}
+
+	if *dryRun {
+		for _, draft := range drafts {
+			fmt.Println(draft)
+		}
+		return
+	}
+
+	client, err := gh.NewDefaultClient()
+	if err != nil {
+		log.Fatalf("fatal: could not initialize client: %v", err)
+	}
+
+	pushed, conflicts, failed := 0, 0, 0
+	for _, draft := range drafts {
+		err := draft.Push(client, *force)
+		switch {
+		case errors.Is(err, pulls.ErrConflict):
+			conflicts++
+			log.Printf("Conflict posting %s: %v", draft, err)
+		case err != nil:
+			failed++
+			log.Printf("Failed to post %s: %+v", draft, err)
+		default:
+			pushed++
+			log.Printf("Posted %s", draft)
+			if err := draft.MarkSent(); err != nil {
+				log.Printf("Posted %s, but couldn't move it to %s: %+v", draft, pulls.SentDraftsDirname, err)
+			}
+		}
+	}
+
+	log.Printf("Pushed %d replies, %d conflicts, %d failed", pushed, conflicts, failed)
+	if conflicts+failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"log"
+
+	"github.com/google/go-github/v88/github"
+	"github.com/parkr/github-utils/gh"
+)
+
+// Lists the NWOs of the organization's repos, skipping archived ones, since
+// they can't have new pull requests or issues.
+func listOrgRepos(client *gh.Client, org string) ([]string, error) {
+	log.Println("listing repos for", org)
+
+	repoNames := []string{}
+	opts := &github.RepositoryListByOrgOptions{
+		Type:        "all",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for {
+		repos, resp, err := client.Repositories.ListByOrg(client.Context, org, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, repo := range repos {
+			if repo.GetArchived() {
+				continue
+			}
+			repoNames = append(repoNames, repo.GetFullName())
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.ListOptions.Page = resp.NextPage
+	}
+	return repoNames, nil
+}
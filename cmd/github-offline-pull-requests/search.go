@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/parkr/github-utils/pulls"
+)
+
+// Builds the search index over everything downloaded to the output directory.
+func index(cwd string, args []string) {
+	flags := flag.NewFlagSet("index", flag.ExitOnError)
+	dir := flags.String("dir", cwd, "Output directory the pull requests were downloaded to, defaults to $CWD.")
+	flags.Parse(args)
+
+	idx, err := pulls.BuildIndex(*dir)
+	if err != nil {
+		log.Fatalf("fatal: could not index %s: %+v", *dir, err)
+	}
+	filename, err := idx.Save(*dir)
+	if err != nil {
+		log.Fatalf("fatal: could not save index: %+v", err)
+	}
+	log.Printf("Indexed %d messages and patches to %s", len(idx.Documents), filename)
+}
+
+// Searches the index for messages and patches matching the query.
+func query(cwd string, args []string) {
+	flags := flag.NewFlagSet("query", flag.ExitOnError)
+	dir := flags.String("dir", cwd, "Output directory the pull requests were downloaded to, defaults to $CWD.")
+	author := flags.String("author", "", "Only match messages by this user.")
+	repo := flags.String("repo", "", "Only match this repository NWO (e.g. parkr/auto-reply).")
+	number := flags.Int("pr", 0, "Only match this pull request or issue number.")
+	since := flags.String("since", "", "Only match messages since this date, e.g. 2006-01-02.")
+	until := flags.String("until", "", "Only match messages before this date, e.g. 2006-01-02.")
+	limit := flags.Int("limit", 20, "The most results to show, or 0 for all of them.")
+	flags.Parse(args)
+
+	q := pulls.ParseQuery(strings.Join(flags.Args(), " "))
+	q.Author = strings.TrimPrefix(*author, "@")
+	q.Repo = *repo
+	q.Number = *number
+	for flagName, value := range map[string]string{"since": *since, "until": *until} {
+		if value == "" {
+			continue
+		}
+		date, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			log.Fatalf("fatal: invalid -%s %q: %v", flagName, value, err)
+		}
+		if flagName == "since" {
+			q.Since = date
+		} else {
+			q.Until = date
+		}
+	}
+
+	idx, err := pulls.LoadIndex(*dir)
+	if os.IsNotExist(err) {
+		log.Fatalf("fatal: no index in %s, run `github-offline-pull-requests index` first", *dir)
+	}
+	if err != nil {
+		log.Fatalf("fatal: could not read index: %+v", err)
+	}
+
+	results := idx.Search(q)
+	if len(results) == 0 {
+		log.Println("no matches")
+		return
+	}
+	for i, result := range results {
+		if *limit > 0 && i == *limit {
+			log.Printf("%d more matches, pass -limit 0 to see them all", len(results)-i)
+			break
+		}
+		fmt.Printf("%s#%d %s %s @%s: %s\n", result.Repo, result.Number, result.Date.Format("2006-01-02"), result.Kind, result.Author, result.Subject)
+		if result.Excerpt != "" {
+			fmt.Printf("    %s\n", result.Excerpt)
+		}
+		fmt.Printf("    %s\n", result.Filename)
+	}
+}
@@ -13,7 +13,9 @@ import (
 
 func main() {
 	var createIssue bool
-	flag.BoolVar(&createIssue, "create", false, "Post the issue to GitHub")
+	flag.BoolVar(&createIssue, "create", false, "Post the issue to GitHub, or update this week's if it's already there")
+	var force bool
+	flag.BoolVar(&force, "force", false, "With -create, post a new issue even if there's already one for this week")
 	var mention string
 	flag.StringVar(&mention, "mention", "", "The user or team to mention at the top of the radar issue")
 	var owner string
@@ -44,17 +46,18 @@ func main() {
 		Mention:   mention,
 		RepoOwner: owner,
 		RepoName:  repo,
+		Force:     force,
 	})
 
 	if createIssue {
-		// Create new issue
+		// Create new issue, or update this week's
 		newIssue, err := r.Create(context.Background())
 		if err != nil {
 			log.Fatalf("fatal: error creating radar issue %#v", err)
 		}
 		log.Println(newIssue.GetHTMLURL())
 
-		// Close the previous issue
+		// Close the previous issue, once the new one is confirmed to exist
 		err = r.ClosePrevious(context.Background())
 		if err != nil {
 			log.Fatalf("fatal: error closing previous radar issue %#v", err)
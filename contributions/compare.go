@@ -0,0 +1,171 @@
+package contributions
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// How a report compares with one of the period before it, for retros.
+type Comparison struct {
+	// The previous period.
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until"`
+	// How many items were in each of the report's sections in each period.
+	Sections []SectionComparison `json:"sections"`
+	// Repos with items or commits in the report, but not the previous one.
+	NewRepos []string `json:"new_repos"`
+	// Pull requests pushed in the previous period which are still open.
+	CarriedOver []Item `json:"carried_over"`
+	// How long shipped pull requests took to merge, on average, or zero if
+	// none were shipped.
+	AverageTimeToMerge         time.Duration `json:"average_time_to_merge"`
+	PreviousAverageTimeToMerge time.Duration `json:"previous_average_time_to_merge"`
+}
+
+type SectionComparison struct {
+	Name     string `json:"name"`
+	Previous int    `json:"previous"`
+	Current  int    `json:"current"`
+}
+
+// Describes the previous period, e.g. "from 2018-01-01 to 2018-01-07".
+func (c *Comparison) Period() string {
+	return describePeriod(c.Since, c.Until)
+}
+
+// The change in the count of the named section, or an empty string if
+// there's no comparison.
+func (c *Comparison) delta(name string) string {
+	if c == nil {
+		return ""
+	}
+	for _, section := range c.Sections {
+		if section.Name == name {
+			return section.Delta()
+		}
+	}
+	return ""
+}
+
+// The change in the section's count, e.g. "+2", "-1" or "±0".
+func (s SectionComparison) Delta() string {
+	switch delta := s.Current - s.Previous; {
+	case delta > 0:
+		return fmt.Sprintf("+%d", delta)
+	case delta < 0:
+		return fmt.Sprintf("%d", delta)
+	default:
+		return "±0"
+	}
+}
+
+// Returns the period of the same length just before this one. A period which
+// hasn't ended is taken to end today.
+func (p Period) Previous(now time.Time) Period {
+	until := p.Until
+	if until.IsZero() {
+		until = time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	}
+	days := int(until.Sub(p.Since).Hours()+12) / 24
+	return Period{Since: p.Since.AddDate(0, 0, -days), Until: p.Since}
+}
+
+// Builds a report of the period before the report's with build, and compares
+// the report with it.
+func AddComparison(report *Report, now time.Time, build func(Period) (*Report, error)) error {
+	previous, err := build(Period{Since: report.Since, Until: report.Until}.Previous(now))
+	if err != nil {
+		return fmt.Errorf("previous period: %w", err)
+	}
+	report.Comparison = Compare(report, previous)
+	return nil
+}
+
+// Compares a report with one of an earlier period.
+func Compare(current, previous *Report) *Comparison {
+	comparison := &Comparison{
+		Since:                      previous.Since,
+		Until:                      previous.Until,
+		NewRepos:                   []string{},
+		CarriedOver:                []Item{},
+		AverageTimeToMerge:         averageTimeToMerge(current),
+		PreviousAverageTimeToMerge: averageTimeToMerge(previous),
+	}
+
+	previousCounts := map[string]int{}
+	for _, section := range previous.Sections {
+		previousCounts[section.Name] = len(section.Items)
+	}
+	for _, section := range current.Sections {
+		comparison.Sections = append(comparison.Sections, SectionComparison{
+			Name:     section.Name,
+			Previous: previousCounts[section.Name],
+			Current:  len(section.Items),
+		})
+	}
+
+	previousRepos := reposTouched(previous)
+	for repo := range reposTouched(current) {
+		if !previousRepos[repo] {
+			comparison.NewRepos = append(comparison.NewRepos, repo)
+		}
+	}
+	sort.Strings(comparison.NewRepos)
+
+	// Pushed pull requests are those which are still open, so these are
+	// the ones from the previous period which haven't been shipped yet.
+	for _, section := range previous.Sections {
+		if section.Name == "Pushed" {
+			comparison.CarriedOver = append(comparison.CarriedOver, section.Items...)
+		}
+	}
+
+	return comparison
+}
+
+func reposTouched(report *Report) map[string]bool {
+	repos := map[string]bool{}
+	for _, section := range report.Sections {
+		for _, item := range section.Items {
+			repos[item.Repo] = true
+		}
+	}
+	for _, repo := range report.Commits {
+		repos[repo.Repo] = true
+	}
+	return repos
+}
+
+func averageTimeToMerge(report *Report) time.Duration {
+	var total time.Duration
+	merged := 0
+	for _, section := range report.Sections {
+		if section.Name != "Shipped" {
+			continue
+		}
+		for _, item := range section.Items {
+			if item.MergedAt != nil {
+				total += item.MergedAt.Sub(item.CreatedAt)
+				merged++
+			}
+		}
+	}
+	if merged == 0 {
+		return 0
+	}
+	return total / time.Duration(merged)
+}
+
+// Formats a duration in days and hours, e.g. "2d 4h", or minutes if it's less
+// than an hour.
+func formatDuration(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd %dh", int(d.Hours())/24, int(d.Hours())%24)
+	}
+}
@@ -0,0 +1,66 @@
+package contributions
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompare(t *testing.T) {
+	at := func(day, hour int) time.Time { return time.Date(2024, 1, day, hour, 0, 0, 0, time.UTC) }
+	merged := func(day, hour int) *time.Time { t := at(day, hour); return &t }
+
+	previous := &Report{
+		Login: "parkr", Since: at(1, 0), Until: at(8, 0),
+		Sections: []Section{
+			{Name: "Pushed", Items: []Item{{Repo: "jekyll/jekyll", Number: 1, URL: "https://github.com/jekyll/jekyll/pull/1", Title: "WIP"}}},
+			{Name: "Shipped", Items: []Item{}},
+		},
+	}
+	current := &Report{
+		Login: "parkr", Since: at(8, 0), Until: at(15, 0),
+		Sections: []Section{
+			{Name: "Pushed", Items: []Item{}},
+			{Name: "Shipped", Items: []Item{
+				{Repo: "jekyll/minima", Number: 2, CreatedAt: at(8, 0), MergedAt: merged(10, 0)},
+				{Repo: "jekyll/jekyll", Number: 3, CreatedAt: at(9, 0), MergedAt: merged(9, 4)},
+			}},
+		},
+		Commits: []RepoCommits{{Repo: "parkr/status", Count: 1}},
+	}
+
+	if previousPeriod := (Period{Since: current.Since, Until: current.Until}).Previous(time.Now()); !previousPeriod.Since.Equal(at(1, 0)) || !previousPeriod.Until.Equal(at(8, 0)) {
+		t.Errorf("expected the week before, got %+v", previousPeriod)
+	}
+
+	comparison := Compare(current, previous)
+	if deltas := []string{comparison.Sections[0].Delta(), comparison.Sections[1].Delta()}; deltas[0] != "-1" || deltas[1] != "+2" {
+		t.Errorf("expected -1 and +2, got %v", deltas)
+	}
+	if strings.Join(comparison.NewRepos, " ") != "jekyll/minima parkr/status" {
+		t.Errorf("expected the new repos, got %v", comparison.NewRepos)
+	}
+	if len(comparison.CarriedOver) != 1 || comparison.CarriedOver[0].Number != 1 {
+		t.Errorf("expected #1 to be carried over, got %+v", comparison.CarriedOver)
+	}
+	if comparison.AverageTimeToMerge != 26*time.Hour || comparison.PreviousAverageTimeToMerge != 0 {
+		t.Errorf("expected an average of 26h, got %v and %v", comparison.AverageTimeToMerge, comparison.PreviousAverageTimeToMerge)
+	}
+
+	current.Comparison = comparison
+	var buf bytes.Buffer
+	if err := (markdownRenderer{}).Render(&buf, current); err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	for _, expected := range []string{
+		"Compared with the previous period, from 2024-01-01 to 2024-01-07:",
+		" * Average time to merge: 1d 2h (previously nothing shipped)\n",
+		"### Shipped (2, +2)\n",
+		"### Carried over (1)\n * [ ] [jekyll/jekyll#1](https://github.com/jekyll/jekyll/pull/1) WIP\n",
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("expected the report to contain %q, got:\n%s", expected, buf.String())
+		}
+	}
+}
@@ -2,68 +2,81 @@ package contributions
 
 import (
 	"bytes"
-	"context"
 	"fmt"
 	"io"
 	"net/url"
 	"strings"
-	"time"
+	"sync"
 
 	"github.com/google/go-github/v88/github"
 	"github.com/parkr/github-utils/gh"
 	"github.com/parkr/github-utils/search"
 )
 
+// How many issues are checked for the user's comments at once.
+const commentCheckConcurrency = 5
+
 type contributionsTracker struct {
-	login, startDate, owner string
+	login string
 
-	startDateAsTime time.Time
+	period Period
+	scope  Scope
 
 	github *gh.Client
-}
 
-func New(client *gh.Client, login, startDate, owner string) *contributionsTracker {
-	startDateAsTime, err := time.Parse("2006-01-02", startDate)
-	if err != nil {
-		panic(err)
-	}
+	mu            sync.Mutex
+	commentChecks map[string]*commentCheck
+	errors        []string
+}
 
-	if owner != "" {
-		owner = "@" + owner
-	}
+func New(client *gh.Client, login string, period Period, scope Scope) *contributionsTracker {
 	return &contributionsTracker{
-		login:           login,
-		owner:           owner,
-		startDate:       startDate,
-		startDateAsTime: startDateAsTime,
-		github:          client,
+		login:         login,
+		period:        period,
+		scope:         scope,
+		github:        client,
+		commentChecks: map[string]*commentCheck{},
 	}
 }
 
+// Writes the report as a Markdown checklist.
 func (c *contributionsTracker) Write(writer io.Writer) error {
-	fmt.Fprintf(writer, "Contributions for %s since %s\n\n", c.login, c.startDate)
-
-	if err := c.addPushedPRs(writer); err != nil {
-		return err
-	}
-
-	if err := c.addShippedPRs(writer); err != nil {
-		return err
-	}
-
-	if err := c.addTrackedIssues(writer); err != nil {
+	report, err := c.Report()
+	if err != nil {
 		return err
 	}
+	return markdownRenderer{}.Render(writer, report)
+}
 
-	if err := c.addContributedIssues(writer); err != nil {
-		return err
+// Searches for the user's contributions and builds a report of them.
+func (c *contributionsTracker) Report() (*Report, error) {
+	report := &Report{Login: c.login, Since: c.period.Since, Until: c.period.Until}
+
+	for _, addSection := range []func() (Section, error){
+		c.pushedPRs,
+		c.shippedPRs,
+		c.closedPRs,
+		c.trackedIssues,
+		c.contributedIssues,
+		c.reviewedPRs,
+	} {
+		section, err := addSection()
+		if err != nil {
+			return nil, err
+		}
+		report.Sections = append(report.Sections, section)
 	}
 
-	if err := c.addReviewedPRs(writer); err != nil {
-		return err
+	// The GraphQL API only adds detail to the searches, so the report is
+	// still worth having without it.
+	commits, err := c.commitsByRepo()
+	if err != nil {
+		c.addError(fmt.Errorf("could not count commits: %w", err))
 	}
+	report.Commits = commits
+	report.Errors = c.errors
 
-	return nil
+	return report, nil
 }
 
 func (c *contributionsTracker) String() (string, error) {
@@ -74,83 +87,118 @@ func (c *contributionsTracker) String() (string, error) {
 	return buf.String(), err
 }
 
-func (c *contributionsTracker) addPushedPRs(buf io.Writer) error {
-	return c.addIssues(buf, "Pushed",
-		fmt.Sprintf("created:>=%s %s author:%s type:pr state:open", c.startDate, c.owner, c.login),
+func (c *contributionsTracker) pushedPRs() (Section, error) {
+	return c.section("Pushed",
+		c.query(c.period.qualifier("created"), "author:"+c.login, "type:pr", "state:open"),
 		nil,
 	)
 }
 
-func (c *contributionsTracker) addShippedPRs(buf io.Writer) error {
-	return c.addIssues(buf, "Shipped",
-		fmt.Sprintf("updated:>=%s %s author:%s type:pr state:closed", c.startDate, c.owner, c.login),
-		func(issue github.Issue) bool {
-			return c.gteStartTime(issue.GetClosedAt().Time)
+// Merged pull requests, with how many lines they added and deleted.
+func (c *contributionsTracker) shippedPRs() (Section, error) {
+	section, err := c.section("Shipped",
+		c.query(c.period.qualifier("closed"), "author:"+c.login, "type:pr", "is:merged"),
+		func(issue github.Issue) (bool, error) {
+			return c.period.Contains(issue.GetClosedAt().Time), nil
 		},
 	)
+	if err != nil {
+		return section, err
+	}
+	if err := c.addPullRequestDetails(&section); err != nil {
+		c.addError(fmt.Errorf("Shipped: could not count lines changed: %w", err))
+	}
+	return section, nil
 }
 
-func (c *contributionsTracker) addTrackedIssues(buf io.Writer) error {
-	return c.addIssues(buf, "Tracked",
-		fmt.Sprintf("created:>=%s %s author:%s type:issue", c.startDate, c.owner, c.login),
-		nil,
+// Pull requests which were closed without being merged.
+func (c *contributionsTracker) closedPRs() (Section, error) {
+	return c.section("Closed",
+		c.query(c.period.qualifier("closed"), "author:"+c.login, "type:pr", "is:unmerged", "state:closed"),
+		func(issue github.Issue) (bool, error) {
+			return c.period.Contains(issue.GetClosedAt().Time), nil
+		},
 	)
 }
 
-func (c *contributionsTracker) addContributedIssues(buf io.Writer) error {
-	return c.addIssues(buf, "Contributed",
-		fmt.Sprintf("updated:>=%s %s commenter:%s type:issue", c.startDate, c.owner, c.login),
-		c.commentedInLastWeek,
+func (c *contributionsTracker) trackedIssues() (Section, error) {
+	return c.section("Tracked",
+		c.query(c.period.qualifier("created"), "author:"+c.login, "type:issue"),
+		nil,
 	)
 }
 
-func (c *contributionsTracker) addReviewedPRs(buf io.Writer) error {
-	return c.addIssues(buf, "Reviewed",
-		fmt.Sprintf("updated:>=%s %s commenter:%s type:pr", c.startDate, c.owner, c.login),
-		c.commentedInLastWeek,
+func (c *contributionsTracker) contributedIssues() (Section, error) {
+	return c.section("Contributed",
+		c.query("updated:>="+c.period.Since.Format(dateFormat), "commenter:"+c.login, "type:issue"),
+		c.commentedInPeriod,
 	)
 }
 
-func (c *contributionsTracker) searchIssues(query string) ([]github.Issue, error) {
-	var issues []github.Issue
-
-	options := &github.SearchOptions{
-		Sort:  "created",
-		Order: "asc",
-		ListOptions: github.ListOptions{
-			Page:    0,
-			PerPage: 100,
-		},
+// Pull requests the user commented on or reviewed, with their verdict if they
+// reviewed it.
+func (c *contributionsTracker) reviewedPRs() (Section, error) {
+	section, err := c.section("Reviewed",
+		c.query("updated:>="+c.period.Since.Format(dateFormat), "commenter:"+c.login, "type:pr"),
+		c.commentedInPeriod,
+	)
+	if err != nil {
+		return section, err
 	}
-	for {
-		result, resp, err := c.github.Search.Issues(context.Background(), query, options)
-		if err != nil {
-			return issues, err
-		}
 
-		for _, issue := range result.Issues {
-			issue := issue
-			issues = append(issues, *issue)
-		}
-		if resp.NextPage == 0 {
-			break
-		}
-		options.Page = resp.NextPage
+	states, err := c.reviewStates()
+	if err != nil {
+		c.addError(fmt.Errorf("Reviewed: could not find review verdicts: %w", err))
+	}
+	for i := range section.Items {
+		section.Items[i].ReviewState = states[section.Items[i].URL]
 	}
+	return section, nil
+}
 
-	return issues, nil
+// Builds a search query of the qualifiers and the tracker's scope.
+func (c *contributionsTracker) query(qualifiers ...string) string {
+	return strings.Join(append(qualifiers, c.scope.qualifiers()...), " ")
 }
 
-func (c *contributionsTracker) addIssues(buf io.Writer, header, query string, filterFunc func(github.Issue) bool) error {
+// Searches for the issues matching the query and filter, and returns them as
+// a section, most recently created first. The filter is run on up to
+// commentCheckConcurrency issues at once. Issues it fails to check are left
+// out, and the errors are added to the report.
+func (c *contributionsTracker) section(name, query string, filterFunc func(github.Issue) (bool, error)) (Section, error) {
 	unfilteredIssues, err := search.SearchIssues(c.github, query)
 	if err != nil {
-		return err
+		return Section{}, err
 	}
 
 	var issues []github.Issue
 	if filterFunc != nil {
-		for _, issue := range unfilteredIssues {
-			if filterFunc(issue) {
+		matches := make([]bool, len(unfilteredIssues))
+		jobs := make(chan int)
+		var wg sync.WaitGroup
+		for i := 0; i < commentCheckConcurrency; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := range jobs {
+					issue := unfilteredIssues[j]
+					matched, err := filterFunc(issue)
+					if err != nil {
+						owner, repo := repoNwo(issue)
+						c.addError(fmt.Errorf("%s: could not check %s/%s#%d: %w", name, owner, repo, issue.GetNumber(), err))
+					}
+					matches[j] = matched
+				}
+			}()
+		}
+		for i := range unfilteredIssues {
+			jobs <- i
+		}
+		close(jobs)
+		wg.Wait()
+
+		for i, issue := range unfilteredIssues {
+			if matches[i] {
 				issues = append(issues, issue)
 			}
 		}
@@ -158,57 +206,84 @@ func (c *contributionsTracker) addIssues(buf io.Writer, header, query string, fi
 		issues = unfilteredIssues
 	}
 
-	fmt.Fprintf(buf, "\n### %s (%d)\n", header, len(issues))
+	section := Section{Name: name, Items: []Item{}}
 	for i := len(issues) - 1; i >= 0; i-- {
-
-		fmt.Fprint(buf, c.formattedIssue(issues[i])+"\n")
+		section.Items = append(section.Items, newItem(issues[i]))
 	}
 
-	fmt.Fprintf(buf, "\n")
+	return section, nil
+}
 
-	return nil
+func (c *contributionsTracker) addError(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.errors = append(c.errors, err.Error())
 }
 
-func (c *contributionsTracker) formattedIssue(issue github.Issue) string {
-	owner, name := repoNwo(issue)
-	return fmt.Sprintf(" * [x] [%s/%s#%d](%s) %s",
-		owner, name,
-		issue.GetNumber(),
-		issue.GetHTMLURL(),
-		issue.GetTitle(),
-	)
+// Whether the user commented on an issue in the period, which is only
+// checked once per issue.
+type commentCheck struct {
+	once      sync.Once
+	commented bool
+	err       error
+}
+
+func (c *contributionsTracker) commentedInPeriod(issue github.Issue) (bool, error) {
+	c.mu.Lock()
+	check, ok := c.commentChecks[issue.GetHTMLURL()]
+	if !ok {
+		check = &commentCheck{}
+		c.commentChecks[issue.GetHTMLURL()] = check
+	}
+	c.mu.Unlock()
+
+	check.once.Do(func() {
+		check.commented, check.err = c.checkCommentedInPeriod(issue)
+	})
+	return check.commented, check.err
 }
 
-func (c *contributionsTracker) commentedInLastWeek(issue github.Issue) bool {
+func (c *contributionsTracker) checkCommentedInPeriod(issue github.Issue) (bool, error) {
 	// Issues the user authored will be in "Pushed" or "Shipped"
 	if issue.User.GetLogin() == c.login {
-		return false
+		return false, nil
 	}
 
-	// Issue was created in duration of interest, so all comments were too
-	if c.gteStartTime(issue.GetCreatedAt().Time) {
-		return true
+	// Issue was created after the period, so all comments were too
+	if !c.period.Until.IsZero() && !issue.GetCreatedAt().Before(c.period.Until) {
+		return false, nil
 	}
 
-	// Comment was posted by user in duration of interest
-	if c.issueCommentsSinceStartDate(issue) {
-		return true
+	// Issue was created in a period which hasn't ended, so all comments were
+	// in it too
+	if c.period.Until.IsZero() && c.period.Contains(issue.GetCreatedAt().Time) {
+		return true, nil
 	}
 
-	// Pull request comment posted by user in duration of interest
-	if c.prReviewCommentsSinceStartDate(issue) {
-		return true
+	// Comment was posted by user in the period
+	if commented, err := c.issueCommentsInPeriod(issue); commented || err != nil {
+		return commented, err
 	}
 
-	return false
+	if !issue.IsPullRequest() {
+		return false, nil
+	}
+
+	// Pull request comment posted by user in the period
+	if commented, err := c.prReviewCommentsInPeriod(issue); commented || err != nil {
+		return commented, err
+	}
+
+	// Pull request review submitted by user in the period
+	return c.prReviewsInPeriod(issue)
 }
 
-func (c *contributionsTracker) issueCommentsSinceStartDate(issue github.Issue) bool {
+func (c *contributionsTracker) issueCommentsInPeriod(issue github.Issue) (bool, error) {
 	owner, name := repoNwo(issue)
 	options := &github.IssueListCommentsOptions{
 		Sort:      github.String("created"),
 		Direction: github.String("asc"),
-		Since:     &c.startDateAsTime,
+		Since:     &c.period.Since,
 		ListOptions: github.ListOptions{
 			Page:    0,
 			PerPage: 100,
@@ -216,16 +291,17 @@ func (c *contributionsTracker) issueCommentsSinceStartDate(issue github.Issue) b
 	}
 	for {
 		comments, resp, err := c.github.Issues.ListComments(
-			context.Background(),
+			c.github.Context,
 			owner, name,
 			issue.GetNumber(),
 			options,
 		)
-		if err == nil {
-			for _, comment := range comments {
-				if comment.User.GetLogin() == c.login && c.gteStartTime(comment.GetCreatedAt().Time) {
-					return true
-				}
+		if err != nil {
+			return false, err
+		}
+		for _, comment := range comments {
+			if comment.User.GetLogin() == c.login && c.period.Contains(comment.GetCreatedAt().Time) {
+				return true, nil
 			}
 		}
 		if resp.NextPage == 0 {
@@ -234,15 +310,15 @@ func (c *contributionsTracker) issueCommentsSinceStartDate(issue github.Issue) b
 		options.Page = resp.NextPage
 	}
 
-	return false
+	return false, nil
 }
 
-func (c *contributionsTracker) prReviewCommentsSinceStartDate(issue github.Issue) bool {
+func (c *contributionsTracker) prReviewCommentsInPeriod(issue github.Issue) (bool, error) {
 	owner, name := repoNwo(issue)
 	options := &github.PullRequestListCommentsOptions{
 		Sort:      "created",
 		Direction: "asc",
-		Since:     c.startDateAsTime,
+		Since:     c.period.Since,
 		ListOptions: github.ListOptions{
 			Page:    0,
 			PerPage: 100,
@@ -250,16 +326,17 @@ func (c *contributionsTracker) prReviewCommentsSinceStartDate(issue github.Issue
 	}
 	for {
 		comments, resp, err := c.github.PullRequests.ListComments(
-			context.Background(),
+			c.github.Context,
 			owner, name,
 			issue.GetNumber(),
 			options,
 		)
-		if err == nil {
-			for _, comment := range comments {
-				if comment.User.GetLogin() == c.login && c.gteStartTime(comment.GetCreatedAt().Time) {
-					return true
-				}
+		if err != nil {
+			return false, err
+		}
+		for _, comment := range comments {
+			if comment.User.GetLogin() == c.login && c.period.Contains(comment.GetCreatedAt().Time) {
+				return true, nil
 			}
 		}
 		if resp.NextPage == 0 {
@@ -268,11 +345,35 @@ func (c *contributionsTracker) prReviewCommentsSinceStartDate(issue github.Issue
 		options.Page = resp.NextPage
 	}
 
-	return false
+	return false, nil
 }
 
-func (c *contributionsTracker) gteStartTime(date time.Time) bool {
-	return date.After(c.startDateAsTime) || date.Equal(c.startDateAsTime)
+// Reviews count even if they have no body, like a bare approval.
+func (c *contributionsTracker) prReviewsInPeriod(issue github.Issue) (bool, error) {
+	owner, name := repoNwo(issue)
+	options := &github.ListOptions{PerPage: 100}
+	for {
+		reviews, resp, err := c.github.PullRequests.ListReviews(
+			c.github.Context,
+			owner, name,
+			issue.GetNumber(),
+			options,
+		)
+		if err != nil {
+			return false, err
+		}
+		for _, review := range reviews {
+			if review.User.GetLogin() == c.login && c.period.Contains(review.GetSubmittedAt().Time) {
+				return true, nil
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		options.Page = resp.NextPage
+	}
+
+	return false, nil
 }
 
 func repoNwo(issue github.Issue) (string, string) {
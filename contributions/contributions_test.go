@@ -1,9 +1,14 @@
 package contributions
 
 import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/google/go-github/v88/github"
+	"github.com/parkr/github-utils/gh/ghtest"
 )
 
 func TestRepoNwo(t *testing.T) {
@@ -28,3 +33,70 @@ func TestRepoNwo(t *testing.T) {
 		}
 	}
 }
+
+func TestCommentedInPeriod(t *testing.T) {
+	var requests atomic.Int32
+	client := ghtest.NewClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.URL.Path {
+		case "/repos/jekyll/jekyll/issues/1/comments", "/repos/jekyll/jekyll/pulls/1/comments":
+			w.Write([]byte(`[]`))
+		case "/repos/jekyll/jekyll/pulls/1/reviews":
+			w.Write([]byte(`[{"user": {"login": "parkr"}, "state": "APPROVED", "submitted_at": "2024-01-15T12:00:00Z"}]`))
+		default:
+			http.Error(w, `{"message": "Server Error"}`, http.StatusInternalServerError)
+		}
+	})
+
+	pr := func(number int) github.Issue {
+		return github.Issue{
+			Number:           github.Int(number),
+			HTMLURL:          github.String(fmt.Sprintf("https://github.com/jekyll/jekyll/pull/%d", number)),
+			User:             &github.User{Login: github.String("dirtyf")},
+			CreatedAt:        &github.Timestamp{Time: time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)},
+			PullRequestLinks: &github.PullRequestLinks{},
+		}
+	}
+	period, _ := ParsePeriod("2024-01-01", "2024-01-31")
+	tracker := New(client, "parkr", period, Scope{})
+
+	for i := 0; i < 2; i++ {
+		commented, err := tracker.commentedInPeriod(pr(1))
+		if err != nil || !commented {
+			t.Errorf("expected a review to count as a comment, got %v (%v)", commented, err)
+		}
+	}
+	if requests.Load() != 3 {
+		t.Errorf("expected the check to be made once, got %d requests", requests.Load())
+	}
+
+	if _, err := tracker.commentedInPeriod(pr(2)); err == nil {
+		t.Errorf("expected an error when comments can't be listed")
+	}
+}
+
+func TestQueryScope(t *testing.T) {
+	period, _ := ParsePeriod("2024-01-01", "")
+	tracker := New(nil, "parkr", period, Scope{
+		Orgs:          []string{"jekyll"},
+		Repos:         []string{"parkr/status"},
+		ExcludeRepos:  []string{"jekyll/sandbox"},
+		Labels:        []string{"good first issue"},
+		ExcludeLabels: []string{"wontfix"},
+		ExcludeBots:   true,
+	})
+	expected := `created:>=2024-01-01 author:parkr type:pr org:jekyll repo:parkr/status -repo:jekyll/sandbox label:"good first issue" -label:wontfix -author:app/dependabot -author:app/renovate -author:app/github-actions`
+	if query := tracker.query(period.qualifier("created"), "author:parkr", "type:pr"); query != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, query)
+	}
+
+	for repo, included := range map[string]bool{"jekyll/jekyll": true, "jekyll/sandbox": false, "parkr/status": true, "parkr/dotfiles": false} {
+		if tracker.scope.includesRepo(repo) != included {
+			t.Errorf("%s: expected included to be %v", repo, included)
+		}
+	}
+
+	if err := (Scope{Repos: []string{"jekyll"}}).Validate(); err == nil {
+		t.Errorf("expected an error for a repo without an owner")
+	}
+}
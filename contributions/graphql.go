@@ -0,0 +1,284 @@
+package contributions
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// How many pull requests are looked up in each GraphQL query.
+const pullRequestsPerQuery = 50
+
+var graphQLQueryCommitContributions = `query($login: String!, $from: DateTime, $to: DateTime) {
+  user(login: $login) {
+    contributionsCollection(from: $from, to: $to) {
+      commitContributionsByRepository(maxRepositories: 100) {
+        repository {
+          nameWithOwner
+        }
+        contributions {
+          totalCount
+        }
+      }
+    }
+  }
+}`
+
+var graphQLQueryReviewContributions = `query($login: String!, $from: DateTime, $to: DateTime, $after: String) {
+  user(login: $login) {
+    contributionsCollection(from: $from, to: $to) {
+      pullRequestReviewContributions(first: 100, after: $after, orderBy: {direction: ASC}) {
+        pageInfo {
+          hasNextPage
+          endCursor
+        }
+        nodes {
+          pullRequest {
+            url
+          }
+          pullRequestReview {
+            state
+          }
+        }
+      }
+    }
+  }
+}`
+
+var graphQLQueryPullRequest = `
+  pr%d: repository(owner: %q, name: %q) {
+    pullRequest(number: %d) {
+      additions
+      deletions
+    }
+  }`
+
+// The errors in a GraphQL response, which come with a 200 OK.
+type graphQLErrors struct {
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func (e graphQLErrors) err() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	messages := []string{}
+	for _, graphQLErr := range e.Errors {
+		messages = append(messages, graphQLErr.Message)
+	}
+	return fmt.Errorf("graphql: %s", strings.Join(messages, "; "))
+}
+
+type commitContributions struct {
+	Data struct {
+		User struct {
+			ContributionsCollection struct {
+				CommitContributionsByRepository []struct {
+					Repository struct {
+						NameWithOwner string `json:"nameWithOwner"`
+					} `json:"repository"`
+					Contributions struct {
+						TotalCount int `json:"totalCount"`
+					} `json:"contributions"`
+				} `json:"commitContributionsByRepository"`
+			} `json:"contributionsCollection"`
+		} `json:"user"`
+	} `json:"data"`
+	graphQLErrors
+}
+
+type reviewContributions struct {
+	Data struct {
+		User struct {
+			ContributionsCollection struct {
+				PullRequestReviewContributions struct {
+					PageInfo struct {
+						HasNextPage bool   `json:"hasNextPage"`
+						EndCursor   string `json:"endCursor"`
+					} `json:"pageInfo"`
+					Nodes []struct {
+						PullRequest struct {
+							URL string `json:"url"`
+						} `json:"pullRequest"`
+						PullRequestReview struct {
+							State string `json:"state"`
+						} `json:"pullRequestReview"`
+					} `json:"nodes"`
+				} `json:"pullRequestReviewContributions"`
+			} `json:"contributionsCollection"`
+		} `json:"user"`
+	} `json:"data"`
+	graphQLErrors
+}
+
+type pullRequestDetails struct {
+	Data map[string]*struct {
+		PullRequest *struct {
+			Additions int `json:"additions"`
+			Deletions int `json:"deletions"`
+		} `json:"pullRequest"`
+	} `json:"data"`
+	graphQLErrors
+}
+
+// Runs the GraphQL query with the variables, decoding the response into data.
+// Errors in the response are returned, too.
+func (c *contributionsTracker) graphQL(query string, variables map[string]any, data interface{ err() error }) error {
+	req, err := c.github.NewRequest(c.github.Context, "POST", "graphql", struct {
+		Query     string         `json:"query"`
+		Variables map[string]any `json:"variables,omitempty"`
+	}{Query: query, Variables: variables})
+	if err != nil {
+		return err
+	}
+	if _, err := c.github.Do(req, data); err != nil {
+		return err
+	}
+	return data.err()
+}
+
+// The variables for each contributionsCollection query of the user's
+// contributions in the period. A collection can't span more than a year, so
+// longer periods are split into years, oldest first. A period which hasn't
+// ended runs up to now.
+func (c *contributionsTracker) collectionVariables() []map[string]any {
+	until := c.period.Until
+	if until.IsZero() {
+		until = time.Now()
+	}
+
+	windows := []map[string]any{}
+	for from := c.period.Since; from.Before(until); from = from.AddDate(1, 0, 0) {
+		to := from.AddDate(1, 0, 0)
+		if to.After(until) {
+			to = until
+		}
+		windows = append(windows, map[string]any{
+			"login": c.login,
+			"from":  from.Format(time.RFC3339),
+			"to":    to.Add(-time.Second).Format(time.RFC3339),
+		})
+	}
+	return windows
+}
+
+// Returns how many commits the user made to each repo in the period, most
+// first, only counting repos in the scope.
+func (c *contributionsTracker) commitsByRepo() ([]RepoCommits, error) {
+	commits := []RepoCommits{}
+	for _, variables := range c.collectionVariables() {
+		var data commitContributions
+		if err := c.graphQL(graphQLQueryCommitContributions, variables, &data); err != nil {
+			return nil, err
+		}
+
+		window := []RepoCommits{}
+		for _, repo := range data.Data.User.ContributionsCollection.CommitContributionsByRepository {
+			name := repo.Repository.NameWithOwner
+			if !c.scope.includesRepo(name) {
+				continue
+			}
+			window = append(window, RepoCommits{Repo: name, Count: repo.Contributions.TotalCount})
+		}
+		commits = mergeCommits(commits, window)
+	}
+	sortCommits(commits)
+	return commits, nil
+}
+
+// Returns the user's verdict on each pull request they reviewed in the
+// period, keyed by its URL: their latest approval, request for changes or
+// dismissal, or that they only commented.
+func (c *contributionsTracker) reviewStates() (map[string]string, error) {
+	states := map[string]string{}
+	for _, variables := range c.collectionVariables() {
+		for {
+			var data reviewContributions
+			if err := c.graphQL(graphQLQueryReviewContributions, variables, &data); err != nil {
+				return nil, err
+			}
+
+			contributions := data.Data.User.ContributionsCollection.PullRequestReviewContributions
+			for _, node := range contributions.Nodes {
+				url, state := node.PullRequest.URL, node.PullRequestReview.State
+				if state != "COMMENTED" || states[url] == "" {
+					states[url] = state
+				}
+			}
+
+			if !contributions.PageInfo.HasNextPage {
+				break
+			}
+			variables["after"] = contributions.PageInfo.EndCursor
+		}
+	}
+	return states, nil
+}
+
+// Fills in how many lines each of the pull requests in the section added and
+// deleted.
+func (c *contributionsTracker) addPullRequestDetails(section *Section) error {
+	for start := 0; start < len(section.Items); start += pullRequestsPerQuery {
+		items := section.Items[start:min(start+pullRequestsPerQuery, len(section.Items))]
+
+		var query strings.Builder
+		query.WriteString("{")
+		for i, item := range items {
+			owner, name, _ := strings.Cut(item.Repo, "/")
+			fmt.Fprintf(&query, graphQLQueryPullRequest, i, owner, name, item.Number)
+		}
+		query.WriteString("\n}")
+
+		// Pull requests in repos which were deleted or made private since
+		// can't be resolved, but the rest still are.
+		var data pullRequestDetails
+		if err := c.graphQL(query.String(), nil, &data); err != nil {
+			if data.Data == nil {
+				return err
+			}
+			log.Printf("error fetching details of some pull requests: %+v", err)
+		}
+		for i := range items {
+			repo := data.Data[fmt.Sprintf("pr%d", i)]
+			if repo == nil || repo.PullRequest == nil {
+				continue
+			}
+			items[i].Additions = repo.PullRequest.Additions
+			items[i].Deletions = repo.PullRequest.Deletions
+		}
+	}
+	return nil
+}
+
+// Sorts commit counts with the most commits first, then by repo.
+func sortCommits(commits []RepoCommits) {
+	sort.Slice(commits, func(i, j int) bool {
+		if commits[i].Count != commits[j].Count {
+			return commits[i].Count > commits[j].Count
+		}
+		return commits[i].Repo < commits[j].Repo
+	})
+}
+
+// Adds the commit counts in src to those for the same repos in dst.
+func mergeCommits(dst, src []RepoCommits) []RepoCommits {
+	for _, repo := range src {
+		found := false
+		for i := range dst {
+			if dst[i].Repo == repo.Repo {
+				dst[i].Count += repo.Count
+				found = true
+				break
+			}
+		}
+		if !found {
+			dst = append(dst, repo)
+		}
+	}
+	sortCommits(dst)
+	return dst
+}
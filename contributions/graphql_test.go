@@ -0,0 +1,133 @@
+package contributions
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/parkr/github-utils/gh/ghtest"
+)
+
+func TestReviewStates(t *testing.T) {
+	pages := []string{
+		`{"data": {"user": {"contributionsCollection": {"pullRequestReviewContributions": {
+			"pageInfo": {"hasNextPage": true, "endCursor": "abc"},
+			"nodes": [
+				{"pullRequest": {"url": "https://github.com/jekyll/jekyll/pull/1"}, "pullRequestReview": {"state": "APPROVED"}},
+				{"pullRequest": {"url": "https://github.com/jekyll/jekyll/pull/2"}, "pullRequestReview": {"state": "COMMENTED"}}
+			]}}}}}`,
+		`{"data": {"user": {"contributionsCollection": {"pullRequestReviewContributions": {
+			"pageInfo": {"hasNextPage": false},
+			"nodes": [
+				{"pullRequest": {"url": "https://github.com/jekyll/jekyll/pull/1"}, "pullRequestReview": {"state": "COMMENTED"}},
+				{"pullRequest": {"url": "https://github.com/jekyll/jekyll/pull/2"}, "pullRequestReview": {"state": "CHANGES_REQUESTED"}}
+			]}}}}}`,
+	}
+	client := ghtest.NewClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Variables map[string]any `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || r.URL.Path != "/graphql" {
+			http.Error(w, `{"message": "Bad Request"}`, http.StatusBadRequest)
+			return
+		}
+		if body.Variables["after"] == "abc" {
+			w.Write([]byte(pages[1]))
+		} else {
+			w.Write([]byte(pages[0]))
+		}
+	})
+
+	period, _ := ParsePeriod("2024-01-01", "2024-01-31")
+	states, err := New(client, "parkr", period, Scope{}).reviewStates()
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	expected := map[string]string{
+		"https://github.com/jekyll/jekyll/pull/1": "APPROVED",
+		"https://github.com/jekyll/jekyll/pull/2": "CHANGES_REQUESTED",
+	}
+	for url, state := range expected {
+		if states[url] != state {
+			t.Errorf("%s: expected %q, got %q", url, state, states[url])
+		}
+	}
+}
+
+func TestGraphQLErrors(t *testing.T) {
+	var data pullRequestDetails
+	if err := json.Unmarshal([]byte(`{"data": {"pr0": null}, "errors": [{"message": "Could not resolve to a Repository"}]}`), &data); err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	if err := data.err(); err == nil || err.Error() != "graphql: Could not resolve to a Repository" {
+		t.Errorf("expected the error in the response, got %v", err)
+	}
+}
+
+func TestCommitsByRepoSplitsYears(t *testing.T) {
+	windows := []string{}
+	client := ghtest.NewClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Variables map[string]any `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || r.URL.Path != "/graphql" {
+			http.Error(w, `{"message": "Bad Request"}`, http.StatusBadRequest)
+			return
+		}
+		windows = append(windows, fmt.Sprintf("%s..%s", body.Variables["from"], body.Variables["to"]))
+		w.Write([]byte(`{"data": {"user": {"contributionsCollection": {"commitContributionsByRepository": [
+			{"repository": {"nameWithOwner": "jekyll/jekyll"}, "contributions": {"totalCount": 2}},
+			{"repository": {"nameWithOwner": "parkr/hello"}, "contributions": {"totalCount": 1}}
+		]}}}}`))
+	})
+
+	period, _ := ParsePeriod("2022-01-01", "2024-06-30")
+	commits, err := New(client, "parkr", period, Scope{}).commitsByRepo()
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+
+	expectedWindows := []string{
+		"2022-01-01T00:00:00Z..2022-12-31T23:59:59Z",
+		"2023-01-01T00:00:00Z..2023-12-31T23:59:59Z",
+		"2024-01-01T00:00:00Z..2024-06-30T23:59:59Z",
+	}
+	if !reflect.DeepEqual(windows, expectedWindows) {
+		t.Errorf("expected queries for %v, got %v", expectedWindows, windows)
+	}
+	expected := []RepoCommits{{Repo: "jekyll/jekyll", Count: 6}, {Repo: "parkr/hello", Count: 3}}
+	if !reflect.DeepEqual(commits, expected) {
+		t.Errorf("expected %v, got %v", expected, commits)
+	}
+}
+
+func TestReportRecordsGraphQLErrors(t *testing.T) {
+	client := ghtest.NewClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/search/issues":
+			w.Write([]byte(`{"total_count": 0, "items": []}`))
+		case "/graphql":
+			w.Write([]byte(`{"data": null, "errors": [{"message": "Something went wrong"}]}`))
+		default:
+			http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+		}
+	})
+
+	period, _ := ParsePeriod("2024-01-01", "2024-01-31")
+	report, err := New(client, "parkr", period, Scope{}).Report()
+	if err != nil {
+		t.Fatalf("expected the report to be built anyway, got %+v", err)
+	}
+	if len(report.Sections) != 6 {
+		t.Errorf("expected every section, got %d", len(report.Sections))
+	}
+	expected := []string{
+		"Reviewed: could not find review verdicts: graphql: Something went wrong",
+		"could not count commits: graphql: Something went wrong",
+	}
+	if !reflect.DeepEqual(report.Errors, expected) {
+		t.Errorf("expected errors %q, got %q", expected, report.Errors)
+	}
+}
@@ -0,0 +1,173 @@
+package contributions
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const dateFormat = "2006-01-02"
+
+// A range of days to report on, from Since up to, but not including, Until.
+// A zero Until means the period hasn't ended.
+type Period struct {
+	Since, Until time.Time
+
+	// The name of a relative period, and the start of the week, month or
+	// quarter it was taken in, which stay the same while Since moves.
+	name   string
+	anchor time.Time
+}
+
+// The names of the relative periods, for use with RelativePeriod.
+var RelativePeriods = []string{"last-week", "last-month", "last-quarter", "calendar-quarter"}
+
+// The intervals a period can be broken down into, for use with Split.
+var Intervals = []string{"week", "month"}
+
+// Returns the period from since to until, both dates like 2006-01-02. Until
+// is inclusive, and may be empty for a period which hasn't ended.
+func ParsePeriod(since, until string) (Period, error) {
+	period := Period{}
+	var err error
+	if period.Since, err = time.Parse(dateFormat, since); err != nil {
+		return period, fmt.Errorf("could not parse since date: %w", err)
+	}
+	if until == "" {
+		return period, nil
+	}
+	if period.Until, err = time.Parse(dateFormat, until); err != nil {
+		return period, fmt.Errorf("could not parse until date: %w", err)
+	}
+	period.Until = period.Until.AddDate(0, 0, 1)
+	if !period.Until.After(period.Since) {
+		return period, fmt.Errorf("until date %s is before since date %s", until, since)
+	}
+	return period, nil
+}
+
+// Returns the named relative period, which runs up to now: the last week,
+// month or three months, or the calendar quarter so far.
+func RelativePeriod(name string, now time.Time) (Period, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	daysSinceMonday := (int(today.Weekday()) + 6) % 7
+	quarter := time.Date(today.Year(), (today.Month()-1)/3*3+1, 1, 0, 0, 0, 0, time.UTC)
+	switch name {
+	case "last-week":
+		return Period{Since: today.AddDate(0, 0, -7), name: name, anchor: today.AddDate(0, 0, -daysSinceMonday)}, nil
+	case "last-month":
+		return Period{Since: today.AddDate(0, -1, 0), name: name, anchor: today.AddDate(0, 0, 1-today.Day())}, nil
+	case "last-quarter":
+		return Period{Since: today.AddDate(0, -3, 0), name: name, anchor: quarter}, nil
+	case "calendar-quarter":
+		return Period{Since: quarter, name: name, anchor: quarter}, nil
+	default:
+		return Period{}, fmt.Errorf("unknown period %q, expected one of: %s", name, strings.Join(RelativePeriods, ", "))
+	}
+}
+
+// Identifies the period, e.g. "2018-01-01" or "2018-01-01-2018-01-31". A
+// relative period is identified by its name and the start of the week, month
+// or quarter it was taken in instead, e.g. "last-week-2018-01-01", so taking
+// it again on another day of that week identifies it the same way.
+func (p Period) Key() string {
+	if p.name != "" {
+		return p.name + "-" + p.anchor.Format(dateFormat)
+	}
+	key := p.Since.Format(dateFormat)
+	if !p.Until.IsZero() {
+		key += "-" + p.Until.AddDate(0, 0, -1).Format(dateFormat)
+	}
+	return key
+}
+
+// Reports whether the time is within the period.
+func (p Period) Contains(t time.Time) bool {
+	return !t.Before(p.Since) && (p.Until.IsZero() || t.Before(p.Until))
+}
+
+// Returns a search qualifier for the field being within the period, e.g.
+// "created:2018-01-01..2018-01-31".
+func (p Period) qualifier(field string) string {
+	if p.Until.IsZero() {
+		return fmt.Sprintf("%s:>=%s", field, p.Since.Format(dateFormat))
+	}
+	return fmt.Sprintf("%s:%s..%s", field, p.Since.Format(dateFormat), p.Until.AddDate(0, 0, -1).Format(dateFormat))
+}
+
+// Splits the period into weeks starting on Mondays, or calendar months. The
+// first and last parts may be shorter. A period which hasn't ended is split
+// up to the end of today.
+func (p Period) Split(interval string, now time.Time) ([]Period, error) {
+	until := p.Until
+	if until.IsZero() {
+		until = time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	}
+
+	var next func(time.Time) time.Time
+	switch interval {
+	case "week":
+		next = func(t time.Time) time.Time {
+			daysUntilMonday := (8 - int(t.Weekday())) % 7
+			if daysUntilMonday == 0 {
+				daysUntilMonday = 7
+			}
+			return t.AddDate(0, 0, daysUntilMonday)
+		}
+	case "month":
+		next = func(t time.Time) time.Time {
+			return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		}
+	default:
+		return nil, fmt.Errorf("unknown interval %q, expected one of: %s", interval, strings.Join(Intervals, ", "))
+	}
+
+	periods := []Period{}
+	for since := p.Since; since.Before(until); since = next(since) {
+		end := next(since)
+		if end.After(until) {
+			end = until
+		}
+		periods = append(periods, Period{Since: since, Until: end})
+	}
+	return periods, nil
+}
+
+// Builds a report for each week or month of the period with build, and
+// combines them into a report of the whole period, broken down into those
+// reports. Items in several of them are listed once, most recent first.
+func BreakdownReport(period Period, interval string, now time.Time, build func(Period) (*Report, error)) (*Report, error) {
+	periods, err := period.Split(interval, now)
+	if err != nil {
+		return nil, err
+	}
+
+	whole := &Report{Since: period.Since, Until: period.Until}
+	for _, part := range periods {
+		report, err := build(part)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", part.Since.Format(dateFormat), err)
+		}
+		whole.Login, whole.Members = report.Login, report.Members
+		for _, message := range report.Errors {
+			whole.Errors = append(whole.Errors, part.Since.Format(dateFormat)+": "+message)
+		}
+		whole.Breakdown = append(whole.Breakdown, report)
+	}
+
+	for i := len(whole.Breakdown) - 1; i >= 0; i-- {
+		report := whole.Breakdown[i]
+		whole.Sections = mergeSections(whole.Sections, report.Sections)
+		whole.Commits = mergeCommits(whole.Commits, report.Commits)
+		for _, group := range report.Groups {
+			j := slices.IndexFunc(whole.Groups, func(g Group) bool { return g.Name == group.Name })
+			if j == -1 {
+				whole.Groups = append(whole.Groups, Group{Name: group.Name})
+				j = len(whole.Groups) - 1
+			}
+			whole.Groups[j].Sections = mergeSections(whole.Groups[j].Sections, group.Sections)
+		}
+	}
+	return whole, nil
+}
@@ -0,0 +1,74 @@
+package contributions
+
+import (
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestPeriods(t *testing.T) {
+	period, err := ParsePeriod("2024-01-01", "2024-01-31")
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	if !period.Until.Equal(date(2024, 2, 1)) || !period.Contains(date(2024, 1, 31).Add(time.Hour)) {
+		t.Errorf("expected the until date to be inclusive, got %+v", period)
+	}
+	if expected := "created:2024-01-01..2024-01-31"; period.qualifier("created") != expected {
+		t.Errorf("expected %q, got %q", expected, period.qualifier("created"))
+	}
+	if _, err := ParsePeriod("2024-01-31", "2024-01-01"); err == nil {
+		t.Errorf("expected an error for an until date before the since date")
+	}
+
+	now := date(2024, 5, 15).Add(10 * time.Hour)
+	quarter, err := RelativePeriod("calendar-quarter", now)
+	if err != nil || !quarter.Since.Equal(date(2024, 4, 1)) || !quarter.Until.IsZero() {
+		t.Errorf("expected the quarter so far, got %+v (%v)", quarter, err)
+	}
+	if expected := "created:>=2024-04-01"; quarter.qualifier("created") != expected {
+		t.Errorf("expected %q, got %q", expected, quarter.qualifier("created"))
+	}
+	for name, expected := range map[string]string{"last-week": "last-week-2024-05-13", "last-month": "last-month-2024-05-01", "last-quarter": "last-quarter-2024-04-01"} {
+		if relative, _ := RelativePeriod(name, now); relative.Key() != expected {
+			t.Errorf("expected %s to be keyed %q, got %q", name, expected, relative.Key())
+		}
+	}
+	if expected := "2024-01-01-2024-01-31"; period.Key() != expected {
+		t.Errorf("expected %q, got %q", expected, period.Key())
+	}
+
+	// 2024-01-01 is a Monday.
+	weeks, err := period.Split("week", now)
+	if err != nil || len(weeks) != 5 || !weeks[4].Since.Equal(date(2024, 1, 29)) || !weeks[4].Until.Equal(date(2024, 2, 1)) {
+		t.Errorf("expected 5 weeks, the last ending with the period, got %+v (%v)", weeks, err)
+	}
+	months, err := quarter.Split("month", now)
+	if err != nil || len(months) != 2 || !months[1].Since.Equal(date(2024, 5, 1)) || !months[1].Until.Equal(date(2024, 5, 16)) {
+		t.Errorf("expected April and May so far, got %+v (%v)", months, err)
+	}
+}
+
+func TestBreakdownReport(t *testing.T) {
+	item := Item{URL: "https://github.com/jekyll/jekyll/pull/1"}
+	period, _ := ParsePeriod("2024-01-01", "2024-02-29")
+	report, err := BreakdownReport(period, "month", time.Now(), func(part Period) (*Report, error) {
+		items := []Item{item}
+		if part.Since.Month() == time.February {
+			items = append([]Item{{URL: "https://github.com/jekyll/jekyll/pull/2"}}, items...)
+		}
+		return &Report{Login: "parkr", Since: part.Since, Until: part.Until, Sections: []Section{{Name: "Reviewed", Items: items}}}, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	if len(report.Breakdown) != 2 || report.Login != "parkr" {
+		t.Fatalf("expected a report for each month, got %+v", report)
+	}
+	if items := report.Sections[0].Items; len(items) != 2 || items[0].URL != "https://github.com/jekyll/jekyll/pull/2" {
+		t.Errorf("expected each item once, most recent first, got %+v", items)
+	}
+}
@@ -0,0 +1,240 @@
+package contributions
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v88/github"
+	"github.com/parkr/github-utils/gh"
+	"github.com/parkr/github-utils/radar"
+)
+
+// A Publisher puts a rendered report somewhere on GitHub, replacing whatever
+// it published for the same report and period before, so publishing again in
+// the same period edits rather than duplicates it.
+type Publisher interface {
+	// Publishes the rendered report, returning the URL it can be seen at.
+	Publish(client *gh.Client, report *Report, rendered string) (string, error)
+}
+
+// Returns the Publisher for a target, which is one of:
+//
+//   - "gist", for a secret gist of your own
+//   - "issue:owner/repo#123", for a comment on the issue
+//   - "radar:owner/repo", for a comment on the repo's open radar issue
+//   - "file:owner/repo/path/to/file.md", for a file in the repo's default
+//     branch, which collects each period's report, most recent first
+//
+// Gists are named with the extension, e.g. ".md". Comments and files mark
+// the report with HTML comments, so they should be Markdown or HTML.
+//
+// Reports are told apart by their scope and options, like the format or how
+// they're grouped, as well as who they're for and the period's Key, so reports
+// scoped differently don't replace each other.
+func NewPublisher(target, extension string, period Period, scope Scope, options ...string) (Publisher, error) {
+	id := reportID{period: period.Key(), variant: reportVariant(scope, options)}
+	kind, location, _ := strings.Cut(target, ":")
+	switch kind {
+	case "gist":
+		return gistPublisher{extension: extension, reportID: id}, nil
+	case "issue":
+		repo, number, ok := strings.Cut(location, "#")
+		owner, name, ok2 := strings.Cut(repo, "/")
+		n, err := strconv.Atoi(number)
+		if !ok || !ok2 || err != nil {
+			return nil, fmt.Errorf("issue target %q should be of the form issue:owner/repo#123", target)
+		}
+		return issueCommentPublisher{owner: owner, repo: name, number: n, reportID: id}, nil
+	case "radar":
+		owner, name, ok := strings.Cut(location, "/")
+		if !ok {
+			return nil, fmt.Errorf("radar target %q should be of the form radar:owner/repo", target)
+		}
+		return issueCommentPublisher{owner: owner, repo: name, reportID: id}, nil
+	case "file":
+		pieces := strings.SplitN(location, "/", 3)
+		if len(pieces) != 3 || pieces[2] == "" {
+			return nil, fmt.Errorf("file target %q should be of the form file:owner/repo/path", target)
+		}
+		return filePublisher{owner: pieces[0], repo: pieces[1], path: pieces[2], reportID: id}, nil
+	default:
+		return nil, fmt.Errorf("unknown publish target %q, expected gist, issue:owner/repo#123, radar:owner/repo or file:owner/repo/path", target)
+	}
+}
+
+// Returns a short, stable hash of the scope and options. The orgs, users,
+// repos and labels are compared regardless of order or case, while the
+// options are compared as given.
+func reportVariant(scope Scope, options []string) string {
+	hash := sha256.New()
+	for _, values := range [][]string{scope.Orgs, scope.Users, scope.Repos, scope.ExcludeRepos, scope.Labels, scope.ExcludeLabels} {
+		normalized := []string{}
+		for _, value := range values {
+			normalized = append(normalized, strings.ToLower(value))
+		}
+		sort.Strings(normalized)
+		fmt.Fprintf(hash, "%q\n", normalized)
+	}
+	fmt.Fprintf(hash, "%t\n%q\n", scope.ExcludeBots, options)
+	return hex.EncodeToString(hash.Sum(nil))[:8]
+}
+
+// What tells a publisher's reports apart, besides who they're for: the key
+// of their period, and a hash of their scope and options.
+type reportID struct {
+	period, variant string
+}
+
+// Identifies a report by who it's for, its period and its variant, e.g.
+// "parkr-2018-01-01-1a2b3c4d" or
+// "jekyll-maintainers-last-week-2018-01-01-1a2b3c4d".
+func (id reportID) key(report *Report) string {
+	return strings.ReplaceAll(report.Login, "/", "-") + "-" + id.period + "-" + id.variant
+}
+
+// Wraps the rendered report in HTML comments which identify it by its key,
+// so it can be found and replaced later.
+func markedReport(key, rendered string) string {
+	return fmt.Sprintf("<!-- github-contributions %s -->\n%s\n<!-- /github-contributions %s -->", key, strings.TrimSpace(rendered), key)
+}
+
+// Replaces the report marked with the key in text with a new one, or adds it
+// to the top if it isn't there.
+func replaceMarkedReport(text, key, rendered string) string {
+	marked := markedReport(key, rendered)
+	start := strings.Index(text, "<!-- github-contributions "+key+" -->")
+	endMarker := "<!-- /github-contributions " + key + " -->"
+	end := strings.Index(text, endMarker)
+	if start == -1 || end < start {
+		if text == "" {
+			return marked + "\n"
+		}
+		return marked + "\n\n" + text
+	}
+	return text[:start] + marked + text[end+len(endMarker):]
+}
+
+type gistPublisher struct {
+	extension string
+	reportID
+}
+
+func (p gistPublisher) Publish(client *gh.Client, report *Report, rendered string) (string, error) {
+	filename := github.GistFilename("contributions-" + p.key(report) + p.extension)
+	files := map[github.GistFilename]github.GistFile{filename: {Content: github.String(rendered)}}
+
+	opts := &github.GistListOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		gists, resp, err := client.Gists.List(client.Context, "", opts)
+		if err != nil {
+			return "", err
+		}
+		for _, gist := range gists {
+			if _, ok := gist.Files[filename]; ok {
+				gist, _, err := client.Gists.Edit(client.Context, gist.GetID(), &github.Gist{Files: files})
+				return gist.GetHTMLURL(), err
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	gist, _, err := client.Gists.Create(client.Context, &github.Gist{
+		Description: github.String("Contributions for " + report.Login + " " + report.Period()),
+		Public:      github.Bool(false),
+		Files:       files,
+	})
+	return gist.GetHTMLURL(), err
+}
+
+// Comments on an issue, or on the open radar issue if there's no number.
+type issueCommentPublisher struct {
+	owner, repo string
+	number      int
+	reportID
+}
+
+func (p issueCommentPublisher) Publish(client *gh.Client, report *Report, rendered string) (string, error) {
+	number := p.number
+	if number == 0 {
+		issue := radar.NewRadar(&radar.RadarConfig{GitHub: client, RepoOwner: p.owner, RepoName: p.repo}).Latest(client.Context)
+		if issue == nil {
+			return "", fmt.Errorf("no open radar issue in %s/%s", p.owner, p.repo)
+		}
+		number = issue.GetNumber()
+	}
+
+	user := client.CurrentGitHubUser()
+	if user == nil {
+		return "", fmt.Errorf("could not tell who you are, to find your comment")
+	}
+	body := markedReport(p.key(report), rendered)
+	marker := "<!-- github-contributions " + p.key(report) + " -->"
+
+	opts := &github.IssueListCommentsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		comments, resp, err := client.Issues.ListComments(client.Context, p.owner, p.repo, number, opts)
+		if err != nil {
+			return "", err
+		}
+		for _, comment := range comments {
+			if comment.GetUser().GetLogin() == user.GetLogin() && strings.Contains(comment.GetBody(), marker) {
+				comment, _, err := client.Issues.EditComment(client.Context, p.owner, p.repo, comment.GetID(), &github.IssueComment{Body: github.String(body)})
+				return comment.GetHTMLURL(), err
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	comment, _, err := client.Issues.CreateComment(client.Context, p.owner, p.repo, number, &github.IssueComment{Body: github.String(body)})
+	return comment.GetHTMLURL(), err
+}
+
+type filePublisher struct {
+	owner, repo, path string
+	reportID
+}
+
+func (p filePublisher) Publish(client *gh.Client, report *Report, rendered string) (string, error) {
+	url := fmt.Sprintf("https://github.com/%s/%s/blob/HEAD/%s", p.owner, p.repo, p.path)
+	opts := &github.RepositoryContentFileOptions{
+		Message: github.String("Update contributions for " + report.Login + " " + report.Period()),
+	}
+
+	existing := ""
+	file, _, resp, err := client.Repositories.GetContents(client.Context, p.owner, p.repo, p.path, nil)
+	switch {
+	case resp != nil && resp.StatusCode == http.StatusNotFound:
+	case err != nil:
+		return "", err
+	case file == nil:
+		return "", fmt.Errorf("%s in %s/%s is a directory", p.path, p.owner, p.repo)
+	default:
+		if existing, err = file.GetContent(); err != nil {
+			return "", err
+		}
+		opts.SHA = file.SHA
+	}
+
+	updated := replaceMarkedReport(existing, p.key(report), rendered)
+	if updated == existing {
+		return url, nil
+	}
+	opts.Content = []byte(updated)
+	if opts.SHA == nil {
+		_, _, err = client.Repositories.CreateFile(client.Context, p.owner, p.repo, p.path, opts)
+	} else {
+		_, _, err = client.Repositories.UpdateFile(client.Context, p.owner, p.repo, p.path, opts)
+	}
+	return url, err
+}
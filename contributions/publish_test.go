@@ -0,0 +1,144 @@
+package contributions
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v88/github"
+	"github.com/parkr/github-utils/gh/ghtest"
+)
+
+func TestReplaceMarkedReport(t *testing.T) {
+	report := &Report{Login: "jekyll/maintainers"}
+	week := func(day int) string {
+		return reportID{period: Period{Since: time.Date(2024, 1, day, 0, 0, 0, 0, time.UTC)}.Key(), variant: "abc"}.key(report)
+	}
+
+	text := replaceMarkedReport("", week(1), "first draft\n")
+	if expected := "<!-- github-contributions jekyll-maintainers-2024-01-01-abc -->\nfirst draft\n<!-- /github-contributions jekyll-maintainers-2024-01-01-abc -->\n"; text != expected {
+		t.Errorf("expected %q, got %q", expected, text)
+	}
+
+	text = replaceMarkedReport(text, week(8), "next week")
+	text = replaceMarkedReport(text, week(1), "final")
+	if strings.Count(text, "<!-- github-contributions ") != 2 || strings.Contains(text, "first draft") {
+		t.Errorf("expected the report to be replaced, got %q", text)
+	}
+	if !strings.HasPrefix(text, "<!-- github-contributions jekyll-maintainers-2024-01-08-abc -->\nnext week") {
+		t.Errorf("expected the newest report first, got %q", text)
+	}
+	if again := replaceMarkedReport(text, week(1), "final"); again != text {
+		t.Errorf("expected publishing the same report to change nothing, got %q", again)
+	}
+
+	// A differently scoped report of the same period is kept alongside it.
+	scopedKey := reportID{period: "2024-01-01", variant: "def"}.key(report)
+	if scoped := replaceMarkedReport(text, scopedKey, "other org"); strings.Count(scoped, "<!-- github-contributions ") != 3 || !strings.Contains(scoped, "final") {
+		t.Errorf("expected a differently scoped report to be added, got %q", scoped)
+	}
+}
+
+func TestReportVariant(t *testing.T) {
+	base := reportVariant(Scope{Orgs: []string{"jekyll", "parkr"}}, []string{"markdown", ""})
+	if len(base) != 8 {
+		t.Errorf("expected a short hash, got %q", base)
+	}
+	if same := reportVariant(Scope{Orgs: []string{"Parkr", "jekyll"}}, []string{"markdown", ""}); same != base {
+		t.Errorf("expected the order and case of orgs not to matter, got %q and %q", base, same)
+	}
+	for _, different := range []string{
+		reportVariant(Scope{Orgs: []string{"jekyll"}}, []string{"markdown", ""}),
+		reportVariant(Scope{Users: []string{"jekyll", "parkr"}}, []string{"markdown", ""}),
+		reportVariant(Scope{Orgs: []string{"jekyll", "parkr"}, ExcludeBots: true}, []string{"markdown", ""}),
+		reportVariant(Scope{Orgs: []string{"jekyll", "parkr"}}, []string{"html", ""}),
+		reportVariant(Scope{Orgs: []string{"jekyll", "parkr"}}, []string{"markdown", "repo"}),
+	} {
+		if different == base {
+			t.Errorf("expected a different scope or options to change the variant %q", base)
+		}
+	}
+}
+
+func TestNewPublisher(t *testing.T) {
+	period, _ := ParsePeriod("2024-01-01", "")
+	id := reportID{period: "2024-01-01", variant: reportVariant(Scope{}, nil)}
+	valid := map[string]Publisher{
+		"gist":                         gistPublisher{extension: ".md", reportID: id},
+		"issue:jekyll/jekyll#123":      issueCommentPublisher{owner: "jekyll", repo: "jekyll", number: 123, reportID: id},
+		"radar:jekyll/radar":           issueCommentPublisher{owner: "jekyll", repo: "radar", reportID: id},
+		"file:jekyll/status/weekly.md": filePublisher{owner: "jekyll", repo: "status", path: "weekly.md", reportID: id},
+	}
+	for target, expected := range valid {
+		publisher, err := NewPublisher(target, ".md", period, Scope{})
+		if err != nil || publisher != expected {
+			t.Errorf("%s: expected %+v, got %+v (%v)", target, expected, publisher, err)
+		}
+	}
+	for _, target := range []string{"issue:jekyll/jekyll", "file:jekyll/status", "radar:jekyll", "slack"} {
+		if _, err := NewPublisher(target, ".md", period, Scope{}); err == nil {
+			t.Errorf("%s: expected an error", target)
+		}
+	}
+}
+
+func TestPublishRelativePeriodAgain(t *testing.T) {
+	gists := []*github.Gist{}
+	client := ghtest.NewClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /gists":
+			json.NewEncoder(w).Encode(gists)
+		case "POST /gists":
+			gist := &github.Gist{}
+			json.NewDecoder(r.Body).Decode(gist)
+			gist.ID = github.String(strconv.Itoa(len(gists) + 1))
+			gists = append(gists, gist)
+			json.NewEncoder(w).Encode(gist)
+		case "PATCH /gists/1":
+			json.NewDecoder(r.Body).Decode(gists[0])
+			json.NewEncoder(w).Encode(gists[0])
+		default:
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+			http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+		}
+	})
+
+	// Monday, then Thursday of the same week, when last week started three
+	// days later.
+	for _, now := range []time.Time{time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC), time.Date(2024, 1, 18, 9, 0, 0, 0, time.UTC)} {
+		period, err := RelativePeriod("last-week", now)
+		if err != nil {
+			t.Fatalf("unexpected error: %+v", err)
+		}
+		publisher, err := NewPublisher("gist", ".md", period, Scope{})
+		if err != nil {
+			t.Fatalf("unexpected error: %+v", err)
+		}
+		report := &Report{Login: "parkr", Since: period.Since}
+		if _, err := publisher.Publish(client, report, "As of "+now.Weekday().String()); err != nil {
+			t.Fatalf("unexpected error: %+v", err)
+		}
+	}
+
+	if len(gists) != 1 {
+		t.Fatalf("expected the report to be published once, got %d gists", len(gists))
+	}
+	for filename, file := range gists[0].Files {
+		if !strings.Contains(string(filename), "last-week-2024-01-15") || file.GetContent() != "As of Thursday" {
+			t.Errorf("expected the gist to be updated, got %s: %q", filename, file.GetContent())
+		}
+	}
+
+	// The next week's report is published separately.
+	period, _ := RelativePeriod("last-week", time.Date(2024, 1, 22, 9, 0, 0, 0, time.UTC))
+	publisher, _ := NewPublisher("gist", ".md", period, Scope{})
+	if _, err := publisher.Publish(client, &Report{Login: "parkr", Since: period.Since}, "Next week"); err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	if len(gists) != 2 {
+		t.Errorf("expected a new gist for the next week, got %d gists", len(gists))
+	}
+}
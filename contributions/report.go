@@ -0,0 +1,440 @@
+package contributions
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/go-github/v88/github"
+)
+
+// A report of the issues and pull requests a user contributed to in a
+// period, split into sections like "Pushed" and "Reviewed". A team's report
+// is named after the team, lists its members, and may also be grouped. A
+// report may also be broken down into a report for each week or month of its
+// period.
+type Report struct {
+	Login   string    `json:"login"`
+	Members []string  `json:"members,omitempty"`
+	Since   time.Time `json:"since"`
+	// The end of the period, exclusive, or zero if it hasn't ended.
+	Until     time.Time `json:"until,omitzero"`
+	Sections  []Section `json:"sections"`
+	Groups    []Group   `json:"groups,omitempty"`
+	Breakdown []*Report `json:"breakdown,omitempty"`
+	// How many commits were made to each repo, most first.
+	Commits []RepoCommits `json:"commits,omitempty"`
+	// Why any issues or pull requests couldn't be checked for comments. They
+	// are left out of the report.
+	Errors []string `json:"errors,omitempty"`
+	// How the report compares with the previous period, if asked.
+	Comparison *Comparison `json:"comparison,omitempty"`
+}
+
+type RepoCommits struct {
+	Repo  string `json:"repo"`
+	Count int    `json:"count"`
+}
+
+// Describes the report's period, e.g. "since 2018-01-01" or "from 2018-01-01
+// to 2018-01-31".
+func (r *Report) Period() string {
+	return describePeriod(r.Since, r.Until)
+}
+
+func describePeriod(since, until time.Time) string {
+	if until.IsZero() {
+		return "since " + since.Format(dateFormat)
+	}
+	return fmt.Sprintf("from %s to %s", since.Format(dateFormat), until.AddDate(0, 0, -1).Format(dateFormat))
+}
+
+// The sections for a single person or repo in a team's report.
+type Group struct {
+	Name     string    `json:"name"`
+	Sections []Section `json:"sections"`
+}
+
+type Section struct {
+	Name  string `json:"name"`
+	Items []Item `json:"items"`
+}
+
+// An issue or pull request in a section of the report.
+type Item struct {
+	Repo          string     `json:"repo"`
+	Number        int        `json:"number"`
+	URL           string     `json:"url"`
+	Title         string     `json:"title"`
+	State         string     `json:"state"`
+	IsPullRequest bool       `json:"is_pull_request"`
+	Author        string     `json:"author"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	ClosedAt      *time.Time `json:"closed_at,omitempty"`
+	MergedAt      *time.Time `json:"merged_at,omitempty"`
+	// For shipped pull requests, how many lines they changed.
+	Additions int `json:"additions,omitempty"`
+	Deletions int `json:"deletions,omitempty"`
+	// For reviewed pull requests, the verdict of the review, e.g. "APPROVED"
+	// or "CHANGES_REQUESTED", if there was one.
+	ReviewState string `json:"review_state,omitempty"`
+	// In a team's report, the members who contributed to it.
+	Contributors []string `json:"contributors,omitempty"`
+}
+
+func newItem(issue github.Issue) Item {
+	owner, name := repoNwo(issue)
+	item := Item{
+		Repo:          owner + "/" + name,
+		Number:        issue.GetNumber(),
+		URL:           issue.GetHTMLURL(),
+		Title:         issue.GetTitle(),
+		State:         issue.GetState(),
+		IsPullRequest: issue.IsPullRequest(),
+		Author:        issue.GetUser().GetLogin(),
+		CreatedAt:     issue.GetCreatedAt().Time,
+		UpdatedAt:     issue.GetUpdatedAt().Time,
+	}
+	if issue.ClosedAt != nil {
+		item.ClosedAt = &issue.ClosedAt.Time
+	}
+	if mergedAt := issue.GetPullRequestLinks().GetMergedAt(); !mergedAt.IsZero() {
+		item.MergedAt = &mergedAt.Time
+	}
+	return item
+}
+
+// Describes how many lines the item changed, the review verdict on it and who
+// contributed to it, whichever are known, e.g. "+10/-2; approved".
+func itemDetails(item Item) string {
+	details := []string{}
+	if item.Additions > 0 || item.Deletions > 0 {
+		details = append(details, fmt.Sprintf("+%d/-%d", item.Additions, item.Deletions))
+	}
+	if item.ReviewState != "" {
+		details = append(details, strings.ReplaceAll(strings.ToLower(item.ReviewState), "_", " "))
+	}
+	if len(item.Contributors) > 0 {
+		details = append(details, "@"+strings.Join(item.Contributors, ", @"))
+	}
+	return strings.Join(details, "; ")
+}
+
+// A Renderer writes a report in some format.
+type Renderer interface {
+	Render(w io.Writer, report *Report) error
+}
+
+// The names of the built-in formats, for use with NewRenderer.
+var Formats = []string{"markdown", "json", "csv", "html"}
+
+// Returns the Renderer for the named format.
+func NewRenderer(format string) (Renderer, error) {
+	switch format {
+	case "markdown":
+		return markdownRenderer{}, nil
+	case "json":
+		return jsonRenderer{}, nil
+	case "csv":
+		return csvRenderer{}, nil
+	case "html":
+		return templateRenderer{htmlReportTmpl}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q, expected one of: %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+// Returns a Renderer which executes the given text/template with the
+// *Report, so teams can match their own status report format. Templates can
+// use the "date" function to format times as 2006-01-02, "details" to
+// describe an item's diffstat, review verdict and contributors, "total" to
+// add up commit counts, and "merge" to describe an average time to merge.
+func NewTemplateRenderer(text string) (Renderer, error) {
+	tmpl, err := template.New("report").Funcs(reportFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return templateRenderer{tmpl}, nil
+}
+
+// Renders a Markdown checklist of each section.
+type markdownRenderer struct{}
+
+func (markdownRenderer) Render(w io.Writer, report *Report) error {
+	fmt.Fprintf(w, "Contributions for %s %s\n\n", report.Login, report.Period())
+	if len(report.Breakdown) > 0 {
+		renderMarkdownBreakdown(w, report)
+	}
+	if report.Comparison != nil {
+		renderMarkdownComparison(w, report.Comparison)
+	}
+	if len(report.Groups) == 0 {
+		if err := renderMarkdownSections(w, report.Sections, report.Comparison); err != nil {
+			return err
+		}
+	}
+	for _, group := range report.Groups {
+		fmt.Fprintf(w, "\n## %s\n", group.Name)
+		if err := renderMarkdownSections(w, group.Sections, nil); err != nil {
+			return err
+		}
+	}
+	if report.Comparison != nil && len(report.Comparison.CarriedOver) > 0 {
+		fmt.Fprintf(w, "\n### Carried over (%d)\n", len(report.Comparison.CarriedOver))
+		for _, item := range report.Comparison.CarriedOver {
+			fmt.Fprint(w, strings.Replace(formattedItem(item), "[x]", "[ ]", 1)+"\n")
+		}
+		if _, err := fmt.Fprintf(w, "\n"); err != nil {
+			return err
+		}
+	}
+	if len(report.Commits) > 0 {
+		fmt.Fprintf(w, "\n### Commits (%d)\n", totalCommits(report.Commits))
+		for _, repo := range report.Commits {
+			fmt.Fprintf(w, " * %s: %d\n", repo.Repo, repo.Count)
+		}
+		if _, err := fmt.Fprintf(w, "\n"); err != nil {
+			return err
+		}
+	}
+	if len(report.Errors) > 0 {
+		fmt.Fprintf(w, "\n### Errors (%d)\n", len(report.Errors))
+		for _, message := range report.Errors {
+			fmt.Fprintf(w, " * %s\n", message)
+		}
+		if _, err := fmt.Fprintf(w, "\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func totalCommits(commits []RepoCommits) int {
+	total := 0
+	for _, repo := range commits {
+		total += repo.Count
+	}
+	return total
+}
+
+// Renders each section, with the change in its count if there's a
+// comparison.
+func renderMarkdownSections(w io.Writer, sections []Section, comparison *Comparison) error {
+	for _, section := range sections {
+		if delta := comparison.delta(section.Name); delta != "" {
+			fmt.Fprintf(w, "\n### %s (%d, %s)\n", section.Name, len(section.Items), delta)
+		} else {
+			fmt.Fprintf(w, "\n### %s (%d)\n", section.Name, len(section.Items))
+		}
+		for _, item := range section.Items {
+			fmt.Fprint(w, formattedItem(item)+"\n")
+		}
+		if _, err := fmt.Fprintf(w, "\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Renders a table of how many items were in each section, in each part of
+// the report's period.
+func renderMarkdownBreakdown(w io.Writer, report *Report) {
+	fmt.Fprint(w, "| Period |")
+	for _, section := range report.Sections {
+		fmt.Fprintf(w, " %s |", section.Name)
+	}
+	fmt.Fprint(w, "\n| --- |"+strings.Repeat(" ---: |", len(report.Sections))+"\n")
+	for _, part := range report.Breakdown {
+		fmt.Fprintf(w, "| %s |", part.Since.Format(dateFormat))
+		for _, section := range part.Sections {
+			fmt.Fprintf(w, " %d |", len(section.Items))
+		}
+		fmt.Fprint(w, "\n")
+	}
+	fmt.Fprint(w, "\n")
+}
+
+func renderMarkdownComparison(w io.Writer, comparison *Comparison) {
+	fmt.Fprintf(w, "Compared with the previous period, %s:\n\n", comparison.Period())
+	fmt.Fprintf(w, " * Average time to merge: %s (previously %s)\n",
+		timeToMerge(comparison.AverageTimeToMerge),
+		timeToMerge(comparison.PreviousAverageTimeToMerge),
+	)
+	if len(comparison.NewRepos) > 0 {
+		fmt.Fprintf(w, " * New repos: %s\n", strings.Join(comparison.NewRepos, ", "))
+	}
+	fmt.Fprint(w, "\n")
+}
+
+// Describes an average time to merge, or that nothing was merged.
+func timeToMerge(d time.Duration) string {
+	if d == 0 {
+		return "nothing shipped"
+	}
+	return formatDuration(d)
+}
+
+func formattedItem(item Item) string {
+	formatted := fmt.Sprintf(" * [x] [%s#%d](%s) %s",
+		item.Repo,
+		item.Number,
+		item.URL,
+		item.Title,
+	)
+	if details := itemDetails(item); details != "" {
+		formatted += " (" + details + ")"
+	}
+	return formatted
+}
+
+type jsonRenderer struct{}
+
+func (jsonRenderer) Render(w io.Writer, report *Report) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// Renders one row per item, with the section and group it's in. A report
+// which is broken down has rows for each part of its period instead, with the
+// date the part starts on, so they can be counted in a spreadsheet.
+type csvRenderer struct{}
+
+func (csvRenderer) Render(w io.Writer, report *Report) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"section", "repo", "number", "title", "state", "pull_request", "author", "url", "created_at", "updated_at", "closed_at", "group", "contributors", "period", "merged_at", "additions", "deletions", "review_state"})
+
+	parts := report.Breakdown
+	if len(parts) == 0 {
+		parts = []*Report{report}
+	}
+	for _, part := range parts {
+		period := ""
+		if len(report.Breakdown) > 0 {
+			period = part.Since.Format(dateFormat)
+		}
+		writeCSVRows(writer, part, period)
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func writeCSVRows(writer *csv.Writer, report *Report, period string) {
+	groups := report.Groups
+	if len(groups) == 0 {
+		groups = []Group{{Sections: report.Sections}}
+	}
+	for _, group := range groups {
+		for _, section := range group.Sections {
+			for _, item := range section.Items {
+				closedAt, mergedAt := "", ""
+				if item.ClosedAt != nil {
+					closedAt = item.ClosedAt.Format(time.RFC3339)
+				}
+				if item.MergedAt != nil {
+					mergedAt = item.MergedAt.Format(time.RFC3339)
+				}
+				writer.Write([]string{
+					section.Name,
+					item.Repo,
+					strconv.Itoa(item.Number),
+					item.Title,
+					item.State,
+					strconv.FormatBool(item.IsPullRequest),
+					item.Author,
+					item.URL,
+					item.CreatedAt.Format(time.RFC3339),
+					item.UpdatedAt.Format(time.RFC3339),
+					closedAt,
+					group.Name,
+					strings.Join(item.Contributors, " "),
+					period,
+					mergedAt,
+					strconv.Itoa(item.Additions),
+					strconv.Itoa(item.Deletions),
+					item.ReviewState,
+				})
+			}
+		}
+	}
+}
+
+// The part of text/template and html/template a templateRenderer uses.
+type reportTemplate interface {
+	Execute(w io.Writer, data any) error
+}
+
+type templateRenderer struct {
+	tmpl reportTemplate
+}
+
+func (r templateRenderer) Render(w io.Writer, report *Report) error {
+	return r.tmpl.Execute(w, report)
+}
+
+var reportFuncs = map[string]any{
+	"date":    func(t time.Time) string { return t.Format(dateFormat) },
+	"details": itemDetails,
+	"total":   totalCommits,
+	"merge":   timeToMerge,
+}
+
+var htmlReportTmpl = htmltemplate.Must(htmltemplate.New("report").Funcs(reportFuncs).Parse(`
+{{- define "sections"}}{{range .}}
+<h3>{{.Name}} ({{len .Items}})</h3>
+<ul>
+{{range .Items}}<li><a href="{{.URL}}">{{.Repo}}#{{.Number}}</a> {{.Title}}{{with details .}} ({{.}}){{end}}</li>
+{{end}}</ul>
+{{end}}{{end -}}
+<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Contributions for {{.Login}} {{.Period}}</title>
+</head>
+<body>
+<h1>Contributions for {{.Login}} {{.Period}}</h1>
+{{if .Members}}<p>{{range $i, $login := .Members}}{{if $i}}, {{end}}@{{$login}}{{end}}</p>{{end}}
+{{with .Comparison}}
+<p>Compared with the previous period, {{.Period}}:</p>
+<table>
+<tr><th>Section</th><th>Previous</th><th>Current</th><th>Change</th></tr>
+{{range .Sections}}<tr><td>{{.Name}}</td><td>{{.Previous}}</td><td>{{.Current}}</td><td>{{.Delta}}</td></tr>
+{{end}}</table>
+<ul>
+<li>Average time to merge: {{merge .AverageTimeToMerge}} (previously {{merge .PreviousAverageTimeToMerge}})</li>
+{{if .NewRepos}}<li>New repos: {{range $i, $repo := .NewRepos}}{{if $i}}, {{end}}{{$repo}}{{end}}</li>{{end}}
+</ul>
+{{if .CarriedOver}}<h3>Carried over ({{len .CarriedOver}})</h3>
+<ul>
+{{range .CarriedOver}}<li><a href="{{.URL}}">{{.Repo}}#{{.Number}}</a> {{.Title}}{{with details .}} ({{.}}){{end}}</li>
+{{end}}</ul>{{end}}
+{{end}}
+{{if .Breakdown}}<table>
+<tr><th>Period</th>{{range .Sections}}<th>{{.Name}}</th>{{end}}</tr>
+{{range .Breakdown}}<tr><td>{{date .Since}}</td>{{range .Sections}}<td>{{len .Items}}</td>{{end}}</tr>
+{{end}}</table>{{end}}
+{{if .Groups}}{{range .Groups}}
+<h2>{{.Name}}</h2>
+{{template "sections" .Sections}}{{end}}{{else}}{{template "sections" .Sections}}{{end}}
+{{if .Commits}}
+<h3>Commits ({{total .Commits}})</h3>
+<ul>
+{{range .Commits}}<li>{{.Repo}}: {{.Count}}</li>
+{{end}}</ul>
+{{end}}
+{{if .Errors}}
+<h3>Errors ({{len .Errors}})</h3>
+<ul>
+{{range .Errors}}<li>{{.}}</li>
+{{end}}</ul>
+{{end}}</body>
+</html>
+`))
@@ -0,0 +1,124 @@
+package contributions
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func testReport() *Report {
+	since := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	return &Report{
+		Login: "parkr",
+		Since: since,
+		Sections: []Section{
+			{Name: "Pushed", Items: []Item{{Repo: "jekyll/jekyll", Number: 6748, URL: "https://github.com/jekyll/jekyll/pull/6748", Title: "WIP: Add basic benchmarking to CI", State: "open", IsPullRequest: true, CreatedAt: since}}},
+			{Name: "Tracked", Items: []Item{}},
+		},
+	}
+}
+
+func TestRenderers(t *testing.T) {
+	examples := map[string]string{
+		"markdown": "Contributions for parkr since 2018-01-01\n\n" +
+			"\n### Pushed (1)\n * [x] [jekyll/jekyll#6748](https://github.com/jekyll/jekyll/pull/6748) WIP: Add basic benchmarking to CI\n\n" +
+			"\n### Tracked (0)\n\n",
+	}
+
+	for _, format := range Formats {
+		renderer, err := NewRenderer(format)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %+v", format, err)
+		}
+		var buf bytes.Buffer
+		if err := renderer.Render(&buf, testReport()); err != nil {
+			t.Fatalf("%s: unexpected error: %+v", format, err)
+		}
+
+		switch format {
+		case "json":
+			var report Report
+			if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+				t.Fatalf("json: unexpected error: %+v", err)
+			}
+			if report.Sections[0].Items[0].Number != 6748 {
+				t.Errorf("json: expected to round-trip, got %+v", report)
+			}
+		case "csv":
+			rows, err := csv.NewReader(&buf).ReadAll()
+			if err != nil {
+				t.Fatalf("csv: unexpected error: %+v", err)
+			}
+			if len(rows) != 2 || rows[1][0] != "Pushed" || rows[1][2] != "6748" {
+				t.Errorf("csv: expected a header and one row, got %q", rows)
+			}
+		default:
+			if expected, ok := examples[format]; ok && buf.String() != expected {
+				t.Errorf("%s: expected:\n%q\ngot:\n%q", format, expected, buf.String())
+			}
+		}
+	}
+
+	renderer, err := NewTemplateRenderer(`{{range .Sections}}{{.Name}}: {{len .Items}} since {{date $.Since}}; {{end}}`)
+	if err != nil {
+		t.Fatalf("template: unexpected error: %+v", err)
+	}
+	var buf bytes.Buffer
+	if err := renderer.Render(&buf, testReport()); err != nil {
+		t.Fatalf("template: unexpected error: %+v", err)
+	}
+	if expected := "Pushed: 1 since 2018-01-01; Tracked: 0 since 2018-01-01; "; buf.String() != expected {
+		t.Errorf("template: expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestMarkdownDetails(t *testing.T) {
+	report := testReport()
+	report.Sections = []Section{
+		{Name: "Shipped", Items: []Item{{Repo: "jekyll/jekyll", Number: 1, URL: "https://github.com/jekyll/jekyll/pull/1", Title: "Fix", Additions: 10, Deletions: 2}}},
+		{Name: "Reviewed", Items: []Item{{Repo: "jekyll/jekyll", Number: 2, URL: "https://github.com/jekyll/jekyll/pull/2", Title: "Docs", ReviewState: "CHANGES_REQUESTED", Contributors: []string{"parkr"}}}},
+	}
+	report.Commits = []RepoCommits{{Repo: "jekyll/jekyll", Count: 3}, {Repo: "jekyll/minima", Count: 1}}
+
+	var buf bytes.Buffer
+	if err := (markdownRenderer{}).Render(&buf, report); err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	expected := "Contributions for parkr since 2018-01-01\n\n" +
+		"\n### Shipped (1)\n * [x] [jekyll/jekyll#1](https://github.com/jekyll/jekyll/pull/1) Fix (+10/-2)\n\n" +
+		"\n### Reviewed (1)\n * [x] [jekyll/jekyll#2](https://github.com/jekyll/jekyll/pull/2) Docs (changes requested; @parkr)\n\n" +
+		"\n### Commits (4)\n * jekyll/jekyll: 3\n * jekyll/minima: 1\n\n"
+	if buf.String() != expected {
+		t.Errorf("expected:\n%q\ngot:\n%q", expected, buf.String())
+	}
+}
+
+func TestCombineReports(t *testing.T) {
+	shared := Item{Repo: "jekyll/jekyll", Number: 1, URL: "https://github.com/jekyll/jekyll/pull/1"}
+	other := Item{Repo: "jekyll/minima", Number: 2, URL: "https://github.com/jekyll/minima/pull/2"}
+	reports := []*Report{
+		{Login: "parkr", Sections: []Section{{Name: "Reviewed", Items: []Item{shared}}}},
+		{Login: "dirtyf", Sections: []Section{{Name: "Reviewed", Items: []Item{other, shared}}}},
+	}
+
+	combined, err := combineReports("jekyll/maintainers", reports, GroupByRepo)
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	if len(combined.Members) != 2 {
+		t.Errorf("expected 2 members, got %v", combined.Members)
+	}
+	items := combined.Sections[0].Items
+	if len(items) != 2 || items[0].URL != shared.URL || len(items[0].Contributors) != 2 {
+		t.Fatalf("expected the shared item once with both contributors, got %+v", items)
+	}
+	if len(combined.Groups) != 2 || combined.Groups[0].Name != "jekyll/jekyll" || len(combined.Groups[0].Sections[0].Items) != 1 {
+		t.Errorf("expected a group per repo, got %+v", combined.Groups)
+	}
+
+	if _, err := combineReports("jekyll/maintainers", reports, "team"); err == nil {
+		t.Errorf("expected an error for an unknown grouping")
+	}
+}
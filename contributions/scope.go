@@ -0,0 +1,86 @@
+package contributions
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The bots left out by Scope.ExcludeBots, as search qualifiers. The search API
+// can't match every bot, so these are the ones which open the most issues and
+// pull requests.
+var botAuthors = []string{"app/dependabot", "app/renovate", "app/github-actions"}
+
+// Which issues and pull requests count towards a report. The scope is added
+// to each search as qualifiers, so GitHub does the filtering.
+type Scope struct {
+	// Only those in any of these orgs, users' repos or repos (like
+	// "jekyll/jekyll"), if any are set.
+	Orgs, Users, Repos []string
+	// None of those in these repos.
+	ExcludeRepos []string
+	// Only those with all of these labels, and none of these.
+	Labels, ExcludeLabels []string
+	// None of those opened by well-known bots, like Dependabot.
+	ExcludeBots bool
+}
+
+// Returns an error if any of the repos aren't like "owner/name".
+func (s Scope) Validate() error {
+	for _, repo := range append(append([]string{}, s.Repos...), s.ExcludeRepos...) {
+		if owner, name, ok := strings.Cut(repo, "/"); !ok || owner == "" || name == "" {
+			return fmt.Errorf("repo %q should be of the form owner/name", repo)
+		}
+	}
+	return nil
+}
+
+// Returns the search qualifiers for the scope. Qualifiers for several orgs,
+// users or repos match any of them, while those for labels match all of them.
+func (s Scope) qualifiers() []string {
+	qualifiers := []string{}
+	add := func(format string, values []string) {
+		for _, value := range values {
+			if strings.ContainsAny(value, " \t") {
+				value = `"` + value + `"`
+			}
+			qualifiers = append(qualifiers, fmt.Sprintf(format, value))
+		}
+	}
+	add("org:%s", s.Orgs)
+	add("user:%s", s.Users)
+	add("repo:%s", s.Repos)
+	add("-repo:%s", s.ExcludeRepos)
+	add("label:%s", s.Labels)
+	add("-label:%s", s.ExcludeLabels)
+	if s.ExcludeBots {
+		add("-author:%s", botAuthors)
+	}
+	return qualifiers
+}
+
+// Reports whether the repo, like "jekyll/jekyll", is in the scope. Used where
+// the API can't filter, like counting commits.
+func (s Scope) includesRepo(repo string) bool {
+	for _, excluded := range s.ExcludeRepos {
+		if strings.EqualFold(repo, excluded) {
+			return false
+		}
+	}
+	if len(s.Orgs) == 0 && len(s.Users) == 0 && len(s.Repos) == 0 {
+		return true
+	}
+	owner, _, _ := strings.Cut(repo, "/")
+	for _, owners := range [][]string{s.Orgs, s.Users} {
+		for _, o := range owners {
+			if strings.EqualFold(owner, o) {
+				return true
+			}
+		}
+	}
+	for _, r := range s.Repos {
+		if strings.EqualFold(repo, r) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+package contributions
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/v88/github"
+	"github.com/parkr/github-utils/gh"
+)
+
+// How a team report is grouped, in addition to its combined sections.
+const (
+	GroupByNone   = ""
+	GroupByPerson = "person"
+	GroupByRepo   = "repo"
+)
+
+// How many members' reports are gathered at once. The search API allows
+// only 30 requests a minute, so there's little to gain from more.
+const teamConcurrency = 3
+
+// Lists the logins of the members of the team, e.g. "jekyll/maintainers".
+func TeamMembers(client *gh.Client, team string) ([]string, error) {
+	org, slug, ok := strings.Cut(team, "/")
+	if !ok {
+		return nil, fmt.Errorf("team %q should be of the form org/slug", team)
+	}
+
+	logins := []string{}
+	opts := &github.TeamListTeamMembersOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		members, resp, err := client.Teams.ListTeamMembersBySlug(client.Context, org, slug, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			logins = append(logins, member.GetLogin())
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return logins, nil
+}
+
+// Gathers the contributions of each of the logins concurrently, and combines
+// them into one report named after the team. Items several members touched
+// appear once in each section, listing all of them as contributors. The
+// report is also grouped by person or by repo, if asked.
+func TeamReport(client *gh.Client, team string, logins []string, period Period, scope Scope, groupBy string) (*Report, error) {
+	reports := make([]*Report, len(logins))
+	errs := make([]error, len(logins))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < teamConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				reports[j], errs[j] = New(client, logins[j], period, scope).Report()
+			}
+		}()
+	}
+	for i := range logins {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", logins[i], err)
+		}
+	}
+
+	return combineReports(team, reports, groupBy)
+}
+
+// Combines the reports of several users into one.
+func combineReports(team string, reports []*Report, groupBy string) (*Report, error) {
+	combined := &Report{Login: team}
+	for _, report := range reports {
+		combined.Members = append(combined.Members, report.Login)
+		combined.Since, combined.Until = report.Since, report.Until
+		combined.Commits = mergeCommits(combined.Commits, report.Commits)
+		for _, message := range report.Errors {
+			combined.Errors = append(combined.Errors, report.Login+": "+message)
+		}
+	}
+
+	// Items are keyed by URL, and keep the order they were first seen in.
+	for _, report := range reports {
+		for i, section := range report.Sections {
+			if i == len(combined.Sections) {
+				combined.Sections = append(combined.Sections, Section{Name: section.Name, Items: []Item{}})
+			}
+			combinedSection := &combined.Sections[i]
+			for _, item := range section.Items {
+				item.Contributors = []string{report.Login}
+				combinedSection.add(item)
+			}
+		}
+	}
+
+	switch groupBy {
+	case GroupByNone:
+	case GroupByPerson:
+		for _, report := range reports {
+			combined.Groups = append(combined.Groups, Group{Name: report.Login, Sections: report.Sections})
+		}
+	case GroupByRepo:
+		repos := map[string]*Group{}
+		for i, section := range combined.Sections {
+			for _, item := range section.Items {
+				group, ok := repos[item.Repo]
+				if !ok {
+					group = &Group{Name: item.Repo}
+					for _, s := range combined.Sections {
+						group.Sections = append(group.Sections, Section{Name: s.Name, Items: []Item{}})
+					}
+					repos[item.Repo] = group
+				}
+				group.Sections[i].Items = append(group.Sections[i].Items, item)
+			}
+		}
+		for _, group := range repos {
+			combined.Groups = append(combined.Groups, *group)
+		}
+		sort.Slice(combined.Groups, func(i, j int) bool { return combined.Groups[i].Name < combined.Groups[j].Name })
+	default:
+		return nil, fmt.Errorf("unknown grouping %q, expected %q or %q", groupBy, GroupByPerson, GroupByRepo)
+	}
+
+	return combined, nil
+}
+
+// Adds the item to the section, or adds its contributors to those of the
+// item if it's already there.
+func (s *Section) add(item Item) {
+	for i := range s.Items {
+		if s.Items[i].URL == item.URL {
+			for _, login := range item.Contributors {
+				if !slices.Contains(s.Items[i].Contributors, login) {
+					s.Items[i].Contributors = append(s.Items[i].Contributors, login)
+				}
+			}
+			return
+		}
+	}
+	s.Items = append(s.Items, item)
+}
+
+// Adds the items in each of the sections to the section of the same index in
+// dst, adding any sections dst doesn't have yet.
+func mergeSections(dst, src []Section) []Section {
+	for i, section := range src {
+		if i == len(dst) {
+			dst = append(dst, Section{Name: section.Name, Items: []Item{}})
+		}
+		for _, item := range section.Items {
+			dst[i].add(item)
+		}
+	}
+	return dst
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sync"
 
 	"gopkg.in/yaml.v2"
 
@@ -34,6 +35,7 @@ type Client struct {
 	Context context.Context
 
 	currentlyAuthedGitHubUser *github.User
+	currentUserMu             sync.Mutex
 }
 
 func loginFromNetrc(rc *netrc.Netrc) (*netrc.Machine, error) {
@@ -103,14 +105,16 @@ func NewDefaultClient() (*Client, error) {
 	}
 
 	return &Client{
-		machine,
-		ghClient,
-		context.Background(),
-		nil,
+		Machine: machine,
+		Client:  ghClient,
+		Context: context.Background(),
 	}, nil
 }
 
 func (c *Client) CurrentGitHubUser() *github.User {
+	c.currentUserMu.Lock()
+	defer c.currentUserMu.Unlock()
+
 	if c.currentlyAuthedGitHubUser == nil {
 		currentlyAuthedUser, _, err := c.Users.Get(c.Context, "")
 		if err != nil {
@@ -0,0 +1,28 @@
+// Package ghtest provides GitHub clients backed by test servers.
+package ghtest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-github/v88/github"
+	"github.com/parkr/github-utils/gh"
+)
+
+// Returns a client whose requests, REST and GraphQL alike, are served by the
+// handler. The server is closed when the test finishes.
+func NewClient(t testing.TB, handler http.HandlerFunc) *gh.Client {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	baseURL := server.URL + "/"
+	ghClient, err := github.NewClient(github.WithURLs(&baseURL, &baseURL))
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	return &gh.Client{Client: ghClient, Context: context.Background()}
+}
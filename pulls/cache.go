@@ -1,10 +1,11 @@
 package pulls
 
 import (
+	"errors"
 	"fmt"
-	"io"
-	"io/ioutil"
-	"net/http"
+	"io/fs"
+	"log"
+	"net/mail"
 	"os"
 	"path/filepath"
 	"sort"
@@ -22,6 +23,14 @@ type OfflineStatusResponse struct {
 	Filename string
 	Number   int
 	Error    error
+
+	// Skipped is true if the PR was unchanged since it was last downloaded.
+	Skipped bool
+
+	// The pull request written, if it was one.
+	PullRequest *github.PullRequest
+	// How many bytes were written to disk.
+	BytesWritten int64
 }
 
 type Comments []Comment
@@ -33,152 +42,378 @@ func (c Comments) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
 type Comment struct {
 	To, Name, Username, Subject, Message string
 	CreatedAt                            time.Time
+
+	// Threading headers. References lists the Message-IDs of every ancestor,
+	// oldest first, ending with InReplyTo.
+	MessageID, InReplyTo string
+	References           []string
 }
 
 func (c Comment) Email() string {
-	return c.Username + "@github"
+	return c.Username + "@users.noreply.github.com"
 }
 
-// Writes a single pull request data on the local disk. It pulls down:
-//  1. A `.patch` file for the pull request.
-//  1. A metadata file, preferably in Markdown or something human-readable.
-//     - Username of author
-//     - PR reviews & comments
-//     - PR comment chain
-func WritePullRequest(client *gh.Client, outputDir string, repo string, pr *github.PullRequest) OfflineStatusResponse {
-	patchFilename, err := WritePatchFile(client, outputDir, pr)
-	if err != nil {
-		return OfflineStatusResponse{Success: false, Error: err, Filename: patchFilename, Number: *pr.Number}
+// Returns an RFC 5322 address with the commenter's display name.
+func (c Comment) From() string {
+	return (&mail.Address{Name: c.Name, Address: c.Email()}).String()
+}
+
+// Makes this comment a reply to parent.
+func (c *Comment) ReplyTo(parent Comment) {
+	c.InReplyTo = parent.MessageID
+	c.References = append(append([]string{}, parent.References...), parent.MessageID)
+}
+
+// Returns a stable Message-ID for a pull request, or for one of its comments
+// or reviews when kind and id are given, so re-running produces identical
+// headers. The format mirrors GitHub's own notification emails.
+func messageID(repo string, number int, kind string, id int64) string {
+	if kind == "" {
+		return fmt.Sprintf("<%s/pull/%d@github.com>", repo, number)
 	}
+	return fmt.Sprintf("<%s/pull/%d/%s%d@github.com>", repo, number, kind, id)
+}
 
-	metadataFilename, err := WriteMetadataFile(client, outputDir, repo, pr)
-	if err != nil {
-		return OfflineStatusResponse{Success: false, Error: err, Filename: metadataFilename, Number: *pr.Number}
+// Like messageID, but for an issue and its comments and events.
+func issueMessageID(repo string, number int, kind string, id int64) string {
+	if kind == "" {
+		return fmt.Sprintf("<%s/issues/%d@github.com>", repo, number)
 	}
+	return fmt.Sprintf("<%s/issues/%d/%s%d@github.com>", repo, number, kind, id)
+}
+
+// How pull requests are written to disk.
+type Options struct {
+	// The directory everything is written to.
+	OutputDir string
+	// How comments are written as messages, and how pull requests are written
+	// as pages. At least one should be set.
+	Writer   MessageWriter
+	Renderer *PageRenderer
+	// Optional. See WritePullRequest.
+	State *SyncState
 
-	return OfflineStatusResponse{Success: true, Error: nil, Filename: patchFilename, Number: *pr.Number}
+	// Whether to download patches (the default) or diffs.
+	PatchType github.RawType
+	// The largest patch written, in bytes. Defaults to DefaultMaxPatchSize.
+	MaxPatchSize int64
+	// Snapshots also writes the full contents of the files each pull request
+	// touches, as of its head commit.
+	Snapshots bool
+
+	// How many pull requests are written at once. Defaults to
+	// DefaultConcurrency.
+	Concurrency int
 }
 
-// Copies the content of the Patch URL for the PR down to a local file called <prNumber>.patch.
-func WritePatchFile(client *gh.Client, outputDir string, pr *github.PullRequest) (string, error) {
-	patchFilename := filepath.Join(outputDir, fmt.Sprintf("%d.patch", *pr.Number))
+// Writes a single pull request data on the local disk. It pulls down:
+//  1. A `.patch` (or `.diff`) file for the pull request.
+//  1. Its description, comments and reviews, as messages a mail client can
+//     read, and/or as a page which can be read in a browser or editor.
+//  1. Optionally, a snapshot of the files it touches.
+//
+// If a sync state is given, PRs which haven't changed since they were last
+// downloaded are skipped, the patch and snapshot are only rewritten if the
+// head changed, and only comments since the last download are fetched.
+func WritePullRequest(client *gh.Client, opts Options, repo string, pr *github.PullRequest) OfflineStatusResponse {
+	state := opts.State
+	patchFilename := opts.PatchFilename(pr.GetNumber())
+	if !state.NeedsSync(repo, pr) && opts.written(pr.GetNumber()) {
+		// Nothing changed, but state from before titles and authors were
+		// recorded is filled in.
+		state.Record(repo, pr)
+		return OfflineStatusResponse{Success: true, Skipped: true, Filename: patchFilename, Number: *pr.Number, PullRequest: pr}
+	}
 
-	resp, err := http.Get(*pr.PatchURL)
-	if err != nil {
-		return patchFilename, err
+	// Patches, snapshots and pages are rewritten in full, while messages are
+	// only appended to.
+	var written int64
+	if _, err := os.Stat(patchFilename); err != nil || state.HeadChanged(repo, pr) {
+		// A patch too large to download is skipped, so the comments and
+		// page of a large pull request can still be read.
+		_, err := WritePatchFile(client, opts, pr)
+		switch {
+		case errors.Is(err, ErrPatchTooLarge):
+			log.Printf("skipping patch of %s#%d: %v", repo, pr.GetNumber(), err)
+		case err != nil:
+			return OfflineStatusResponse{Success: false, Error: err, Filename: patchFilename, Number: *pr.Number}
+		default:
+			written += diskUsage(patchFilename)
+		}
 	}
 
-	patchContents, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return patchFilename, err
+	if opts.Snapshots {
+		if _, err := os.Stat(opts.SnapshotDirname(pr.GetNumber())); err != nil || state.HeadChanged(repo, pr) {
+			dir, err := WriteSnapshot(client, opts, pr)
+			if err != nil {
+				return OfflineStatusResponse{Success: false, Error: err, Filename: dir, Number: *pr.Number}
+			}
+			written += diskUsage(dir)
+		}
+	}
+
+	if opts.Writer != nil {
+		before := diskUsage(opts.Writer.Filename(opts.OutputDir, pr.GetNumber()))
+		// Without a file to append to, say after switching formats, every
+		// comment is fetched again, not just those since the last sync.
+		since := state.Since(repo, pr)
+		if before == 0 {
+			since = time.Time{}
+		}
+		metadataFilename, err := WriteMetadataFile(client, opts.Writer, opts.OutputDir, repo, pr, since)
+		if err != nil {
+			return OfflineStatusResponse{Success: false, Error: err, Filename: metadataFilename, Number: *pr.Number}
+		}
+		written += diskUsage(metadataFilename) - before
+	}
+
+	if opts.Renderer != nil {
+		pageFilename, err := WritePageFile(client, opts, repo, pr)
+		if err != nil {
+			return OfflineStatusResponse{Success: false, Error: err, Filename: pageFilename, Number: *pr.Number}
+		}
+		written += diskUsage(pageFilename)
+		patchFilename = pageFilename
 	}
-	defer resp.Body.Close()
 
-	return patchFilename, ioutil.WriteFile(patchFilename, patchContents, 0600)
+	state.Record(repo, pr)
+	return OfflineStatusResponse{Success: true, Error: nil, Filename: patchFilename, Number: *pr.Number, PullRequest: pr, BytesWritten: written}
 }
 
-// Writes a file containing metadata for
-func WriteMetadataFile(client *gh.Client, outputDir string, repo string, pr *github.PullRequest) (string, error) {
-	metadataFilename := filepath.Join(outputDir, fmt.Sprintf("%d.mbox", *pr.Number))
+// Returns the size of the file, or of every file in the directory, or zero
+// if there isn't one.
+func diskUsage(path string) int64 {
+	var size int64
+	filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
 
-	f, err := os.OpenFile(metadataFilename, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+// Reports whether the pull request has been written in each of the formats
+// asked for, e.g. so switching formats doesn't skip unchanged pull requests.
+func (o Options) written(number int) bool {
+	if o.Writer != nil {
+		if _, err := os.Stat(o.Writer.Filename(o.OutputDir, number)); err != nil {
+			return false
+		}
+	}
+	if o.Renderer != nil {
+		if _, err := os.Stat(o.Renderer.Filename(o.OutputDir, number)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Writes the pull request's description, comments and reviews as messages,
+// in the format of the given writer. Only comments and reviews made since the
+// given time are fetched; pass the zero time to fetch all of them.
+func WriteMetadataFile(client *gh.Client, writer MessageWriter, outputDir string, repo string, pr *github.PullRequest, since time.Time) (string, error) {
+	comments, err := PullRequestMessages(client, repo, pr, since)
 	if err != nil {
-		return metadataFilename, err
+		return writer.Filename(outputDir, pr.GetNumber()), err
 	}
-	defer f.Close()
+	return writer.WriteMessages(outputDir, pr.GetNumber(), comments)
+}
 
+// Fetches the pull request's description, and the comments, reviews and line
+// comments made since the given time, as threaded messages sorted by when
+// they were created.
+func PullRequestMessages(client *gh.Client, repo string, pr *github.PullRequest, since time.Time) (Comments, error) {
 	comments := Comments{}
-	pieces := strings.Split(repo, "/")
-	owner, repoName := pieces[0], pieces[1]
-
-	var to string
-	if hostname, err := os.Hostname(); err == nil {
-		to += hostname
-	} else {
-		to += "localhost"
-	}
-	if currentUser := client.CurrentGitHubUser(); currentUser != nil {
-		to = *currentUser.Login + "@" + to
-	} else {
-		to = "mbox@" + to
-	}
+	owner, repoName := splitRepo(repo)
+	to := localRecipient(client)
 
 	// Add the PR
-	comments = append(comments, Comment{
+	prComment := Comment{
 		To:        to,
 		Name:      userName(pr.User),
 		Username:  pr.GetUser().GetLogin(),
 		Subject:   pr.GetTitle(),
 		Message:   pr.GetBody(),
 		CreatedAt: pr.GetCreatedAt().Time,
-	})
+		MessageID: messageID(repo, pr.GetNumber(), "", 0),
+	}
+	comments = append(comments, prComment)
 
 	// Fetch the comments in the PR
-	issueComments, err := GetPullRequestComments(client, owner, repoName, *pr.Number)
+	issueComments, err := GetPullRequestComments(client, owner, repoName, *pr.Number, since)
+	if err != nil {
+		return nil, err
+	}
 	for _, comment := range issueComments {
-		comments = append(comments, Comment{
+		c := Comment{
 			To:        to,
 			Name:      userName(comment.User),
 			Username:  comment.GetUser().GetLogin(),
 			Subject:   "Re: " + pr.GetTitle(),
 			Message:   comment.GetBody(),
 			CreatedAt: comment.GetCreatedAt().Time,
-		})
+			MessageID: messageID(repo, pr.GetNumber(), "c", comment.GetID()),
+		}
+		c.ReplyTo(prComment)
+		comments = append(comments, c)
+	}
+
+	// Fetch the reviews. Line comments made as part of a review are added
+	// below, so only reviews with a verdict or summary get their own message.
+	// Older reviews aren't written again, but line comments may still reply
+	// to them.
+	reviews, err := GetPullRequestReviews(client, owner, repoName, *pr.Number)
+	if err != nil {
+		return nil, err
+	}
+	reviewComments := map[int64]Comment{}
+	for _, review := range reviews {
+		if review.GetState() == "PENDING" {
+			continue
+		}
+		if review.GetState() == "COMMENTED" && review.GetBody() == "" {
+			continue
+		}
+		c := Comment{
+			To:        to,
+			Name:      userName(review.User),
+			Username:  review.GetUser().GetLogin(),
+			Subject:   "Re: " + pr.GetTitle(),
+			Message:   reviewBody(review),
+			CreatedAt: review.GetSubmittedAt().Time,
+			MessageID: messageID(repo, pr.GetNumber(), "review", review.GetID()),
+		}
+		c.ReplyTo(prComment)
+		reviewComments[review.GetID()] = c
+		if !c.CreatedAt.Before(since) {
+			comments = append(comments, c)
+		}
 	}
 
 	// Fetch the line comments on the diff
-	//prComments, err = GetPullRequestLineComments(client,pr)
-	// for _, comment := range prComments {
-	//     comments = append(comments, Comment{
-	//         Name:      userName(comment.User),
-	//         Username:  *comment.User.Login,
-	//         Subject:   "Re: " + *pr.Title,
-	//         Message:   *comment.Body,
-	//         CreatedAt: *comment.CreatedAt,
-	//     })
-	// }
+	prComments, err := GetPullRequestLineComments(client, owner, repoName, *pr.Number, since)
+	if err != nil {
+		return nil, err
+	}
+	// Replies to line comments thread under the comment they reply to, and
+	// other line comments under the review they were part of, if it has its
+	// own message. The API lists them oldest first, so parents come first.
+	lineComments := map[int64]Comment{}
+	for _, comment := range prComments {
+		c := Comment{
+			To:        to,
+			Name:      userName(comment.User),
+			Username:  comment.GetUser().GetLogin(),
+			Subject:   "Re: " + pr.GetTitle(),
+			Message:   unifiedCommentBody(comment),
+			CreatedAt: comment.GetCreatedAt().Time,
+			MessageID: messageID(repo, pr.GetNumber(), "r", comment.GetID()),
+		}
+		if parent, ok := lineComments[comment.GetInReplyTo()]; ok {
+			c.ReplyTo(parent)
+		} else if comment.GetInReplyTo() != 0 {
+			// The parent was fetched in an earlier sync.
+			parent := Comment{MessageID: messageID(repo, pr.GetNumber(), "r", comment.GetInReplyTo())}
+			parent.ReplyTo(prComment)
+			c.ReplyTo(parent)
+		} else if parent, ok := reviewComments[comment.GetPullRequestReviewID()]; ok {
+			c.ReplyTo(parent)
+		} else {
+			c.ReplyTo(prComment)
+		}
+		lineComments[comment.GetID()] = c
+		comments = append(comments, c)
+	}
 
 	// Sort the comments by when the comment was created
 	sort.Stable(comments)
 
-	for _, comment := range comments {
-		if err := writeCommentAsMbox(comment, f); err != nil {
-			return metadataFilename, err
-		}
-	}
-
-	return metadataFilename, nil
+	return comments, nil
 }
 
-// Write a comment to the buffer in mbox format.
-func writeCommentAsMbox(comment Comment, buf io.Writer) error {
-	mailtime := comment.CreatedAt.Format("Mon Jan 2 15:04:05 2006")
-	mailtime2 := comment.CreatedAt.Format("Mon, 2 Jan 2006 15:04:05 -0700")
-	fmt.Fprintf(buf, "From %s %s\n", comment.Email(), mailtime)
-	fmt.Fprintf(buf, "Return-Path: <%s>\n", comment.Email())
-	fmt.Fprintf(buf, "Delivered-To: %s\n", comment.To)
-	fmt.Fprintf(buf, "Envelope-To: %s\n", comment.To)
-	fmt.Fprintf(buf, "Delivery-Date: %s\n", mailtime2)
-	fmt.Fprintf(buf, "From: %s\n", comment.Email())
-	fmt.Fprintf(buf, "To: %s\n", comment.To)
-	fmt.Fprintf(buf, "Subject: %s\n", comment.Subject)
-	fmt.Fprintf(buf, "Date: %s\n", mailtime2)
-	fmt.Fprintf(buf, "Status: RO\n")
-	fmt.Fprintf(buf, "\n%s\n\n", comment.Message)
-	return nil
+func userName(user *github.User) string {
+	if user.GetName() != "" {
+		return user.GetName()
+	}
+	return user.GetLogin()
 }
 
-func userName(user *github.User) string {
-	if user.Name != nil {
-		return *user.Name
+// Returns the address messages are delivered to: the current user at this
+// machine's hostname.
+func localRecipient(client *gh.Client) string {
+	var to string
+	if hostname, err := os.Hostname(); err == nil {
+		to += hostname
+	} else {
+		to += "localhost"
+	}
+	if currentUser := client.CurrentGitHubUser(); currentUser != nil {
+		to = *currentUser.Login + "@" + to
+	} else {
+		to = "mbox@" + to
 	}
-	return *user.Login
+	return to
 }
 
+// Renders a line comment with the file, line and diff hunk it refers to,
+// quoted like an email reply, followed by the comment itself.
 func unifiedCommentBody(comment *github.PullRequestComment) string {
 	if comment.DiffHunk == nil {
-		return *comment.Body
+		return comment.GetBody()
+	}
+
+	var body strings.Builder
+	body.WriteString(comment.GetPath())
+	if line := commentLines(comment); line != "" {
+		body.WriteString(" " + line)
+	}
+	if comment.GetLine() == 0 && comment.GetOriginalLine() != 0 {
+		body.WriteString(" (outdated)")
+	}
+	body.WriteString(":\n\n")
+	for _, hunkLine := range strings.Split(comment.GetDiffHunk(), "\n") {
+		body.WriteString("> " + hunkLine + "\n")
+	}
+	body.WriteString("\n" + comment.GetBody())
+	return body.String()
+}
+
+// Returns a description of the line(s) a comment refers to, e.g. "line 4" or
+// "lines 2-4", preferring the current position in the diff over the original.
+func commentLines(comment *github.PullRequestComment) string {
+	start, end := comment.GetStartLine(), comment.GetLine()
+	if end == 0 {
+		start, end = comment.GetOriginalStartLine(), comment.GetOriginalLine()
+	}
+	switch {
+	case end == 0:
+		return ""
+	case start == 0 || start == end:
+		return fmt.Sprintf("line %d", end)
+	default:
+		return fmt.Sprintf("lines %d-%d", start, end)
+	}
+}
+
+// Renders a review's verdict followed by its summary.
+func reviewBody(review *github.PullRequestReview) string {
+	var verdict string
+	switch review.GetState() {
+	case "APPROVED":
+		verdict = "Approved these changes."
+	case "CHANGES_REQUESTED":
+		verdict = "Requested changes."
+	case "DISMISSED":
+		verdict = "Review dismissed."
+	default:
+		verdict = "Reviewed."
+	}
+	if review.GetBody() == "" {
+		return verdict
 	}
-	// TODO: fix this so the diff comment makes sense
-	return *comment.DiffHunk + "\n\n" + *comment.Body
+	return verdict + "\n\n" + review.GetBody()
 }
@@ -0,0 +1,80 @@
+package pulls
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v88/github"
+)
+
+func TestUnifiedCommentBody(t *testing.T) {
+	examples := []struct {
+		comment  *github.PullRequestComment
+		expected string
+	}{
+		{
+			&github.PullRequestComment{Body: github.String("Looks good.")},
+			"Looks good.",
+		},
+		{
+			&github.PullRequestComment{
+				Body:     github.String("Typo here."),
+				Path:     github.String("README.md"),
+				Line:     github.Int(2),
+				DiffHunk: github.String("@@ -1,1 +1,2 @@\n hello\n+wrold"),
+			},
+			"README.md line 2:\n\n> @@ -1,1 +1,2 @@\n>  hello\n> +wrold\n\nTypo here.",
+		},
+		{
+			&github.PullRequestComment{
+				Body:              github.String("Extract this?"),
+				Path:              github.String("main.go"),
+				OriginalStartLine: github.Int(4),
+				OriginalLine:      github.Int(6),
+				DiffHunk:          github.String("@@ -4,3 +4,3 @@"),
+			},
+			"main.go lines 4-6 (outdated):\n\n> @@ -4,3 +4,3 @@\n\nExtract this?",
+		},
+	}
+
+	for _, example := range examples {
+		actual := unifiedCommentBody(example.comment)
+		if actual != example.expected {
+			t.Fatalf("expected body: %q, actual body: %q", example.expected, actual)
+		}
+	}
+}
+
+func TestWriteCommentAsMbox(t *testing.T) {
+	parent := Comment{MessageID: messageID("parkr/hello", 1, "", 0)}
+	comment := Comment{
+		To:        "parkr@localhost",
+		Name:      "Parker Moore",
+		Username:  "parkr",
+		Subject:   "Re: Add greeting",
+		Message:   "From the top:\n>From before\nFrom here",
+		CreatedAt: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		MessageID: messageID("parkr/hello", 1, "c", 42),
+	}
+	comment.ReplyTo(parent)
+
+	var buf strings.Builder
+	if err := writeCommentAsMbox(comment, &buf); err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	actual := buf.String()
+
+	for _, expected := range []string{
+		"From parkr@users.noreply.github.com Thu Jan 2 03:04:05 2020\n",
+		"From: \"Parker Moore\" <parkr@users.noreply.github.com>\n",
+		"Message-ID: <parkr/hello/pull/1/c42@github.com>\n",
+		"In-Reply-To: <parkr/hello/pull/1@github.com>\n",
+		"References: <parkr/hello/pull/1@github.com>\n",
+		"\n>From the top:\n>>From before\n>From here\n",
+	} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("expected mbox to contain %q, got:\n%s", expected, actual)
+		}
+	}
+}
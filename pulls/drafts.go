@@ -0,0 +1,334 @@
+package pulls
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v88/github"
+	"github.com/parkr/github-utils/gh"
+)
+
+// The directory in the output directory where replies composed offline are
+// queued until they're pushed, and where they're moved once they've been.
+const (
+	DraftsDirname     = "drafts"
+	SentDraftsDirname = "sent"
+)
+
+// The kinds of reply a draft can be posted as.
+const (
+	DraftComment     = "comment"
+	DraftLineComment = "line-comment"
+	DraftReply       = "reply"
+	DraftReview      = "review"
+)
+
+// ErrConflict is returned when a draft can no longer be posted as written,
+// e.g. because the line it comments on has changed since it was drafted.
+var ErrConflict = errors.New("conflict")
+
+// A reply composed offline. Drafts are stored as email messages: headers,
+// a blank line, then the Markdown body. A message saved from a mail client in
+// reply to a downloaded comment works as-is, because its In-Reply-To header
+// identifies the pull request and comment. Replies to issues are posted as
+// comments. Otherwise, these headers apply:
+//
+//	Repo: parkr/github-utils
+//	Pull: 123
+//	Path: pulls/cache.go       (line comments)
+//	Line: 42 or 40-42          (line comments)
+//	Side: RIGHT or LEFT        (line comments, default RIGHT)
+//	Commit: <sha>              (the head the line refers to)
+//	Review: APPROVE, REQUEST_CHANGES or COMMENT
+type Draft struct {
+	Filename string
+
+	Repo              string
+	Number            int
+	Path, Side        string
+	StartLine, Line   int
+	CommitID          string
+	InReplyToComment  int64
+	ReviewEvent, Body string
+}
+
+// Matches the Message-IDs generated by messageID and issueMessageID.
+var messageIDPattern = regexp.MustCompile(`^<([^/]+/[^/]+)/(?:pull|issues)/(\d+)(?:/(c|r|e|review)(\d+))?@github\.com>$`)
+
+// Returns what kind of reply the draft will be posted as.
+func (d *Draft) Kind() string {
+	switch {
+	case d.ReviewEvent != "":
+		return DraftReview
+	case d.InReplyToComment != 0:
+		return DraftReply
+	case d.Path != "":
+		return DraftLineComment
+	default:
+		return DraftComment
+	}
+}
+
+func (d *Draft) String() string {
+	return fmt.Sprintf("%s on %s#%d (%s)", d.Kind(), d.Repo, d.Number, d.Filename)
+}
+
+// Parses a draft. The defaultRepo is used if the draft doesn't specify one.
+func ParseDraft(r io.Reader, defaultRepo string) (*Draft, error) {
+	message, err := mail.ReadMessage(bufio.NewReader(r))
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(message.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	header := message.Header
+	draft := &Draft{
+		Repo:        defaultRepo,
+		Path:        header.Get("Path"),
+		Side:        strings.ToUpper(header.Get("Side")),
+		CommitID:    header.Get("Commit"),
+		ReviewEvent: strings.ToUpper(header.Get("Review")),
+		Body:        strings.TrimSpace(string(body)),
+	}
+
+	// A reply to a downloaded message knows what it's replying to.
+	if inReplyTo := strings.TrimSpace(header.Get("In-Reply-To")); inReplyTo != "" {
+		matches := messageIDPattern.FindStringSubmatch(inReplyTo)
+		if matches == nil {
+			return nil, fmt.Errorf("In-Reply-To %s isn't a pull request or issue message", inReplyTo)
+		}
+		draft.Repo = matches[1]
+		draft.Number, _ = strconv.Atoi(matches[2])
+		if matches[3] == "r" {
+			draft.InReplyToComment, _ = strconv.ParseInt(matches[4], 10, 64)
+		}
+	}
+
+	if repo := header.Get("Repo"); repo != "" {
+		draft.Repo = repo
+	}
+	if pull := header.Get("Pull"); pull != "" {
+		if draft.Number, err = strconv.Atoi(pull); err != nil {
+			return nil, fmt.Errorf("invalid Pull %q: %w", pull, err)
+		}
+	}
+	if line := header.Get("Line"); line != "" {
+		start, end, isRange := strings.Cut(line, "-")
+		if !isRange {
+			start, end = "", start
+		}
+		draft.Line, err = strconv.Atoi(strings.TrimSpace(end))
+		if err == nil && start != "" {
+			draft.StartLine, err = strconv.Atoi(strings.TrimSpace(start))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid Line %q: %w", line, err)
+		}
+	}
+	if draft.Side == "" {
+		draft.Side = "RIGHT"
+	}
+
+	if err := draft.Validate(); err != nil {
+		return nil, err
+	}
+	if draft.Body == "" && draft.ReviewEvent != "APPROVE" {
+		return nil, fmt.Errorf("empty body")
+	}
+	return draft, nil
+}
+
+// Reports whether the draft identifies something it can be posted to.
+func (d *Draft) Validate() error {
+	switch {
+	case d.Repo == "" || !strings.Contains(d.Repo, "/"):
+		return fmt.Errorf("missing Repo")
+	case d.Number == 0:
+		return fmt.Errorf("missing Pull")
+	case d.Path != "" && d.Line == 0:
+		return fmt.Errorf("line comment on %s is missing a Line", d.Path)
+	case d.Side != "LEFT" && d.Side != "RIGHT":
+		return fmt.Errorf("invalid Side %q", d.Side)
+	}
+	switch d.ReviewEvent {
+	case "", "APPROVE", "REQUEST_CHANGES", "COMMENT":
+	default:
+		return fmt.Errorf("invalid Review %q", d.ReviewEvent)
+	}
+	return nil
+}
+
+// Writes the draft in the format ParseDraft reads.
+func (d *Draft) Write(w io.Writer) error {
+	fmt.Fprintf(w, "Repo: %s\n", d.Repo)
+	fmt.Fprintf(w, "Pull: %d\n", d.Number)
+	if d.InReplyToComment != 0 {
+		fmt.Fprintf(w, "In-Reply-To: %s\n", messageID(d.Repo, d.Number, "r", d.InReplyToComment))
+	}
+	if d.Path != "" {
+		fmt.Fprintf(w, "Path: %s\n", d.Path)
+		if d.StartLine != 0 {
+			fmt.Fprintf(w, "Line: %d-%d\n", d.StartLine, d.Line)
+		} else {
+			fmt.Fprintf(w, "Line: %d\n", d.Line)
+		}
+		fmt.Fprintf(w, "Side: %s\n", d.Side)
+	}
+	if d.CommitID != "" {
+		fmt.Fprintf(w, "Commit: %s\n", d.CommitID)
+	}
+	if d.ReviewEvent != "" {
+		fmt.Fprintf(w, "Review: %s\n", d.ReviewEvent)
+	}
+	_, err := fmt.Fprintf(w, "\n%s\n", d.Body)
+	return err
+}
+
+// Saves a new draft in the output directory's drafts directory, returning
+// the path it was written to.
+func SaveDraft(outputDir string, draft *Draft) (string, error) {
+	dir := filepath.Join(outputDir, DraftsDirname)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	f, err := os.CreateTemp(dir, fmt.Sprintf("%d-%s-*.eml", draft.Number, draft.Kind()))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	draft.Filename = f.Name()
+	if err := draft.Write(f); err != nil {
+		return draft.Filename, err
+	}
+	return draft.Filename, f.Close()
+}
+
+// Reads every draft queued in the output directory, by filename. Drafts
+// which can't be parsed are returned as errors alongside the others.
+func LoadDrafts(outputDir, defaultRepo string) ([]*Draft, []error) {
+	dir := filepath.Join(outputDir, DraftsDirname)
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	drafts, errs := []*Draft{}, []error{}
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		filename := filepath.Join(dir, entry.Name())
+		f, err := os.Open(filename)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		draft, err := ParseDraft(f, defaultRepo)
+		f.Close()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", filename, err))
+			continue
+		}
+		draft.Filename = filename
+		drafts = append(drafts, draft)
+	}
+
+	sort.SliceStable(drafts, func(i, j int) bool { return drafts[i].Filename < drafts[j].Filename })
+	return drafts, errs
+}
+
+// Posts the draft to GitHub. Line comments and reviews drafted against an
+// older head commit return ErrConflict, because their lines may have moved,
+// unless force is set. Those without a commit are posted against the head.
+func (d *Draft) Push(client *gh.Client, force bool) error {
+	owner, name := splitRepo(d.Repo)
+	ctx := client.Context
+
+	if d.Kind() == DraftLineComment || d.Kind() == DraftReview {
+		pr, _, err := client.PullRequests.Get(ctx, owner, name, d.Number)
+		if err != nil {
+			return err
+		}
+		head := pr.GetHead().GetSHA()
+		switch {
+		case d.CommitID == "":
+			d.CommitID = head
+		case d.CommitID != head && !force:
+			return fmt.Errorf("%w: drafted against %s, but the head is now %s", ErrConflict, shortSHA(d.CommitID), shortSHA(head))
+		case d.CommitID != head && d.Kind() == DraftReview:
+			// Line comments keep their commit, so GitHub positions them on the
+			// lines they were written against. Reviews have no lines to keep.
+			d.CommitID = head
+		}
+	}
+
+	var err error
+	switch d.Kind() {
+	case DraftComment:
+		_, _, err = client.Issues.CreateComment(ctx, owner, name, d.Number, &github.IssueComment{
+			Body: github.String(d.Body),
+		})
+	case DraftReply:
+		_, _, err = client.PullRequests.CreateCommentInReplyTo(ctx, owner, name, d.Number, d.Body, d.InReplyToComment)
+	case DraftLineComment:
+		comment := &github.PullRequestComment{
+			Body:     github.String(d.Body),
+			CommitID: github.String(d.CommitID),
+			Path:     github.String(d.Path),
+			Line:     github.Int(d.Line),
+			Side:     github.String(d.Side),
+		}
+		if d.StartLine != 0 {
+			comment.StartLine = github.Int(d.StartLine)
+			comment.StartSide = github.String(d.Side)
+		}
+		_, _, err = client.PullRequests.CreateComment(ctx, owner, name, d.Number, comment)
+	case DraftReview:
+		_, _, err = client.PullRequests.CreateReview(ctx, owner, name, d.Number, &github.PullRequestReviewRequest{
+			CommitID: github.String(d.CommitID),
+			Body:     github.String(d.Body),
+			Event:    github.String(d.ReviewEvent),
+		})
+	}
+
+	// GitHub rejects line comments on lines which aren't in the diff.
+	var errResp *github.ErrorResponse
+	if errors.As(err, &errResp) && errResp.Response != nil && errResp.Response.StatusCode == http.StatusUnprocessableEntity {
+		return fmt.Errorf("%w: %v", ErrConflict, err)
+	}
+	return err
+}
+
+// Moves a pushed draft into the sent directory so it isn't pushed again.
+func (d *Draft) MarkSent() error {
+	sentDir := filepath.Join(filepath.Dir(d.Filename), SentDraftsDirname)
+	if err := os.MkdirAll(sentDir, 0700); err != nil {
+		return err
+	}
+	return os.Rename(d.Filename, filepath.Join(sentDir, filepath.Base(d.Filename)))
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
@@ -0,0 +1,51 @@
+package pulls
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseDraft(t *testing.T) {
+	examples := []struct {
+		input    string
+		expected Draft
+	}{
+		{
+			"Pull: 12\n\nSounds good!\n",
+			Draft{Repo: "parkr/hello", Number: 12, Side: "RIGHT", Body: "Sounds good!"},
+		},
+		{
+			"From: parkr@example.com\nIn-Reply-To: <parkr/other/pull/3/r456@github.com>\n\nFixed.\n",
+			Draft{Repo: "parkr/other", Number: 3, InReplyToComment: 456, Side: "RIGHT", Body: "Fixed."},
+		},
+		{
+			"Pull: 12\nPath: main.go\nLine: 4-6\nCommit: abc123\n\nExtract this?\n",
+			Draft{Repo: "parkr/hello", Number: 12, Path: "main.go", StartLine: 4, Line: 6, Side: "RIGHT", CommitID: "abc123", Body: "Extract this?"},
+		},
+		{
+			"Repo: parkr/other\nPull: 1\nReview: approve\n\n",
+			Draft{Repo: "parkr/other", Number: 1, Side: "RIGHT", ReviewEvent: "APPROVE"},
+		},
+	}
+
+	for _, example := range examples {
+		actual, err := ParseDraft(strings.NewReader(example.input), "parkr/hello")
+		if err != nil {
+			t.Fatalf("input: %q, unexpected error: %+v", example.input, err)
+		}
+		if *actual != example.expected {
+			t.Fatalf("input: %q, expected: %+v, actual: %+v", example.input, example.expected, *actual)
+		}
+	}
+
+	for _, input := range []string{
+		"\n\nNo pull request.\n",
+		"Pull: 12\n\n",
+		"Pull: 12\nPath: main.go\n\nNo line.\n",
+		"In-Reply-To: <1234@example.com>\n\nNot ours.\n",
+	} {
+		if _, err := ParseDraft(strings.NewReader(input), "parkr/hello"); err == nil {
+			t.Errorf("input: %q, expected an error", input)
+		}
+	}
+}
@@ -0,0 +1,462 @@
+package pulls
+
+import (
+	"encoding/gob"
+	"html"
+	"io"
+	"io/fs"
+	"mime"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// The name of the file in the output directory which holds the search index.
+const IndexFilename = ".github-offline-index.gob"
+
+// A message, patch or page which has been indexed.
+type IndexedDocument struct {
+	Repo   string
+	Number int
+	// One of "pull", "issue", "comment", "review", "line-comment", "event",
+	// "patch" or "page".
+	Kind      string
+	Author    string
+	Subject   string
+	Date      time.Time
+	MessageID string
+	Filename  string
+	Text      string
+}
+
+// Where a term appears in a document.
+type Posting struct {
+	Document  int
+	Positions []int
+}
+
+// An inverted index of everything downloaded into an output directory:
+// titles, descriptions, comments, reviews, patches and pages. Phrases can be
+// searched for, since the position of each term is recorded.
+type Index struct {
+	Documents []IndexedDocument
+	Postings  map[string][]Posting
+}
+
+// A search of the index. A document matches if it contains every term and
+// phrase, and matches each of the filters which are set.
+type Query struct {
+	Terms   []string
+	Phrases [][]string
+
+	Author string
+	Repo   string
+	Number int
+	// Only documents dated within this range, if set.
+	Since, Until time.Time
+}
+
+// A document which matched a query, with an excerpt around the first match.
+type SearchResult struct {
+	IndexedDocument
+	Score   int
+	Excerpt string
+}
+
+// Matches the patches and diffs written by WritePatchFile.
+var patchFilenamePattern = regexp.MustCompile(`^(\d+)\.(?:patch|diff)$`)
+
+// Matches the pages written by a PageRenderer, but not its index pages.
+var pageFilenamePattern = regexp.MustCompile(`^(\d+)\.(?:md|html)$`)
+
+// Matches what's left out of a page's text: stylesheets and tags.
+var htmlMarkupPattern = regexp.MustCompile(`(?s)<style>.*?</style>|<[^>]*>`)
+
+// Splits text into lowercase terms of letters and digits.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Builds an index of the messages, patches and pages in the output directory
+// and any directories below it, such as those written for several repos.
+// Drafts and snapshots aren't indexed.
+func BuildIndex(outputDir string) (*Index, error) {
+	index := &Index{Postings: map[string][]Posting{}}
+
+	// Patches and pages don't say which repo they're from, or who wrote
+	// them, so they're indexed last, with those of the pull request they
+	// belong to.
+	descriptions := map[string]IndexedDocument{}
+	patches, pages := []string{}, []string{}
+
+	err := filepath.WalkDir(outputDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := entry.Name()
+		if entry.IsDir() {
+			if path != outputDir && (strings.HasPrefix(name, ".") || name == DraftsDirname || strings.HasSuffix(name, ".files")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		var messages []*mail.Message
+		switch parent := filepath.Base(filepath.Dir(path)); {
+		case strings.HasSuffix(name, ".mbox"):
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			if messages, err = readMbox(f); err != nil {
+				return err
+			}
+		case parent == "cur" || parent == "new":
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			message, err := mail.ReadMessage(f)
+			if err != nil {
+				return err
+			}
+			messages = []*mail.Message{message}
+		case patchFilenamePattern.MatchString(name):
+			patches = append(patches, path)
+		case pageFilenamePattern.MatchString(name):
+			pages = append(pages, path)
+		}
+
+		for _, message := range messages {
+			doc, text := messageDocument(message)
+			doc.Filename = path
+			index.add(doc, text)
+			if doc.Kind == "pull" {
+				descriptions[filepath.Join(filepath.Dir(path), strconv.Itoa(doc.Number))] = doc
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Without a description, what the sync state recorded about the pull
+	// request is used instead.
+	states := map[string]*SyncState{}
+	describe := func(path string, number int) (IndexedDocument, bool) {
+		dir := filepath.Dir(path)
+		if description, ok := descriptions[filepath.Join(dir, strconv.Itoa(number))]; ok {
+			return description, true
+		}
+		if _, ok := states[dir]; !ok {
+			states[dir], _ = LoadSyncState(dir)
+		}
+		return syncedPullRequest(states[dir], number), false
+	}
+
+	for _, path := range patches {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		number, _ := strconv.Atoi(patchFilenamePattern.FindStringSubmatch(filepath.Base(path))[1])
+		description, _ := describe(path, number)
+		index.add(IndexedDocument{
+			Repo:     description.Repo,
+			Number:   number,
+			Kind:     "patch",
+			Author:   description.Author,
+			Subject:  description.Subject,
+			Date:     description.Date,
+			Filename: path,
+		}, string(contents))
+	}
+
+	// A page repeats its pull request's messages, so it's only indexed when
+	// they weren't downloaded too.
+	for _, path := range pages {
+		number, _ := strconv.Atoi(pageFilenamePattern.FindStringSubmatch(filepath.Base(path))[1])
+		description, described := describe(path, number)
+		if described {
+			continue
+		}
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		text := string(contents)
+		if filepath.Ext(path) == ".html" {
+			text = html.UnescapeString(htmlMarkupPattern.ReplaceAllString(text, ""))
+		}
+		index.add(IndexedDocument{
+			Repo:     description.Repo,
+			Number:   number,
+			Kind:     "page",
+			Author:   description.Author,
+			Subject:  description.Subject,
+			Date:     description.Date,
+			Filename: path,
+		}, text)
+	}
+
+	return index, nil
+}
+
+// Describes the pull request as recorded in the sync state, leaving the repo
+// empty if it's unclear which one it's from.
+func syncedPullRequest(state *SyncState, number int) IndexedDocument {
+	doc := IndexedDocument{Number: number}
+	if state == nil {
+		return doc
+	}
+	for repo, repoState := range state.Repos {
+		prState, ok := repoState.PullRequests[number]
+		if !ok {
+			continue
+		}
+		if doc.Repo != "" {
+			return IndexedDocument{Number: number}
+		}
+		doc = IndexedDocument{
+			Repo:    repo,
+			Number:  number,
+			Kind:    "pull",
+			Author:  prState.Author,
+			Subject: prState.Title,
+			Date:    prState.CreatedAt,
+		}
+	}
+	return doc
+}
+
+// Describes a downloaded message, identifying what it's from by its
+// Message-ID, and returns the text to index.
+func messageDocument(message *mail.Message) (IndexedDocument, string) {
+	header := message.Header
+	doc := IndexedDocument{MessageID: header.Get("Message-ID")}
+
+	decoder := &mime.WordDecoder{}
+	if subject, err := decoder.DecodeHeader(header.Get("Subject")); err == nil {
+		doc.Subject = subject
+	}
+	if date, err := header.Date(); err == nil {
+		doc.Date = date
+	}
+	if from, err := mail.ParseAddress(header.Get("From")); err == nil {
+		doc.Author, _, _ = strings.Cut(from.Address, "@")
+	}
+
+	if matches := messageIDPattern.FindStringSubmatch(doc.MessageID); matches != nil {
+		doc.Repo = matches[1]
+		doc.Number, _ = strconv.Atoi(matches[2])
+		switch matches[3] {
+		case "":
+			doc.Kind = "pull"
+			if strings.Contains(doc.MessageID, "/issues/") {
+				doc.Kind = "issue"
+			}
+		case "c":
+			doc.Kind = "comment"
+		case "r":
+			doc.Kind = "line-comment"
+		case "review":
+			doc.Kind = "review"
+		case "e":
+			doc.Kind = "event"
+		}
+	}
+
+	body, err := io.ReadAll(message.Body)
+	if err != nil {
+		return doc, doc.Subject
+	}
+	doc.Text = strings.TrimSpace(string(body))
+	return doc, doc.Subject + "\n" + doc.Text
+}
+
+// Adds a document to the index, indexing the given text.
+func (ix *Index) add(doc IndexedDocument, text string) {
+	if doc.Text == "" {
+		doc.Text = text
+	}
+	id := len(ix.Documents)
+	ix.Documents = append(ix.Documents, doc)
+
+	positions := map[string][]int{}
+	for position, term := range tokenize(text) {
+		positions[term] = append(positions[term], position)
+	}
+	for term, termPositions := range positions {
+		ix.Postings[term] = append(ix.Postings[term], Posting{Document: id, Positions: termPositions})
+	}
+}
+
+// Writes the index to the output directory.
+func (ix *Index) Save(outputDir string) (string, error) {
+	filename := filepath.Join(outputDir, IndexFilename)
+	return filename, writeFileAtomically(filename, func(f *os.File) error {
+		return gob.NewEncoder(f).Encode(ix)
+	})
+}
+
+// Reads the index written to the output directory by Save.
+func LoadIndex(outputDir string) (*Index, error) {
+	f, err := os.Open(filepath.Join(outputDir, IndexFilename))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	index := &Index{}
+	if err := gob.NewDecoder(f).Decode(index); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// Parses a query's text into terms and phrases. Phrases are in double
+// quotes, e.g. `retry "exponential backoff"`.
+func ParseQuery(text string) Query {
+	query := Query{}
+	for i, part := range strings.Split(text, `"`) {
+		terms := tokenize(part)
+		switch {
+		case len(terms) == 0:
+		case i%2 == 1 && len(terms) > 1:
+			query.Phrases = append(query.Phrases, terms)
+		default:
+			query.Terms = append(query.Terms, terms...)
+		}
+	}
+	return query
+}
+
+// Returns the documents matching the query, best matches first: those with
+// the most matches, then the most recent.
+func (ix *Index) Search(query Query) []SearchResult {
+	// Phrases need all of their terms, in order.
+	required := append([]string{}, query.Terms...)
+	for _, phrase := range query.Phrases {
+		required = append(required, phrase...)
+	}
+
+	scores := map[int]int{}
+	for i := range ix.Documents {
+		if ix.matchesFilters(i, query) {
+			scores[i] = 0
+		}
+	}
+	for _, term := range required {
+		matched := map[int]int{}
+		for _, posting := range ix.Postings[term] {
+			if score, ok := scores[posting.Document]; ok {
+				matched[posting.Document] = score + len(posting.Positions)
+			}
+		}
+		scores = matched
+	}
+	for _, phrase := range query.Phrases {
+		for doc := range scores {
+			if !ix.containsPhrase(doc, phrase) {
+				delete(scores, doc)
+			}
+		}
+	}
+
+	results := []SearchResult{}
+	for doc, score := range scores {
+		result := SearchResult{IndexedDocument: ix.Documents[doc], Score: score}
+		if len(required) > 0 {
+			result.Excerpt = excerpt(result.Text, required[0])
+		}
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Date.After(results[j].Date)
+	})
+	return results
+}
+
+func (ix *Index) matchesFilters(doc int, query Query) bool {
+	d := ix.Documents[doc]
+	switch {
+	case query.Author != "" && !strings.EqualFold(d.Author, query.Author):
+		return false
+	case query.Repo != "" && !strings.EqualFold(d.Repo, query.Repo):
+		return false
+	case query.Number != 0 && d.Number != query.Number:
+		return false
+	case !query.Since.IsZero() && d.Date.Before(query.Since):
+		return false
+	case !query.Until.IsZero() && !d.Date.Before(query.Until):
+		return false
+	}
+	return true
+}
+
+// Reports whether the phrase's terms appear consecutively in the document.
+func (ix *Index) containsPhrase(doc int, phrase []string) bool {
+	positions := make([]map[int]bool, len(phrase))
+	for i, term := range phrase {
+		positions[i] = map[int]bool{}
+		for _, posting := range ix.Postings[term] {
+			if posting.Document == doc {
+				for _, position := range posting.Positions {
+					positions[i][position] = true
+				}
+			}
+		}
+	}
+	for start := range positions[0] {
+		found := true
+		for i := 1; i < len(phrase) && found; i++ {
+			found = positions[i][start+i]
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the line of text where the term first appears, trimmed to a
+// reasonable length.
+func excerpt(text, term string) string {
+	for _, line := range strings.Split(text, "\n") {
+		// Lowercasing can change how many bytes a rune takes, so keep where
+		// each byte of the lowercased line came from in the original.
+		var lower strings.Builder
+		offsets := []int{}
+		for i, r := range line {
+			lowered := strings.ToLower(string(r))
+			lower.WriteString(lowered)
+			for range len(lowered) {
+				offsets = append(offsets, i)
+			}
+		}
+		offsets = append(offsets, len(line))
+
+		i := strings.Index(lower.String(), term)
+		if i == -1 {
+			continue
+		}
+		matchStart, matchEnd := offsets[i], offsets[i+len(term)]
+		start, end := max(0, matchStart-60), min(len(line), matchEnd+60)
+		return strings.TrimSpace(strings.ToValidUTF8(line[start:end], ""))
+	}
+	return ""
+}
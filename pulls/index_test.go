@@ -0,0 +1,146 @@
+package pulls
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v88/github"
+)
+
+func TestIndexSearch(t *testing.T) {
+	dir := t.TempDir()
+	pr := Comment{Username: "parkr", Subject: "Add retries", Message: "From now on, requests are retried.", CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), MessageID: messageID("parkr/hello", 1, "", 0)}
+	comment := Comment{Username: "jekyllbot", Subject: "Re: Add retries", Message: "Please use exponential backoff between retries.", CreatedAt: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), MessageID: messageID("parkr/hello", 1, "c", 2)}
+	comment.ReplyTo(pr)
+	if _, err := (MboxWriter{}).WriteMessages(dir, 1, Comments{pr, comment}); err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "1.patch"), []byte("+func backoff(attempt int) time.Duration {\n"), 0600); err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+
+	built, err := BuildIndex(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	if _, err := built.Save(dir); err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	index, err := LoadIndex(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	if len(index.Documents) != 3 {
+		t.Fatalf("expected 3 documents, got %d", len(index.Documents))
+	}
+
+	for _, test := range []struct {
+		text     string
+		query    Query
+		expected []string
+	}{
+		{text: "retries", expected: []string{"comment", "pull"}},
+		{text: `"exponential backoff"`, expected: []string{"comment"}},
+		{text: `"backoff exponential"`, expected: []string{}},
+		{text: "backoff", expected: []string{"comment", "patch"}},
+		{text: "retries", query: Query{Author: "parkr"}, expected: []string{"pull"}},
+		{text: "retries", query: Query{Since: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)}, expected: []string{"comment"}},
+		{text: "retries", query: Query{Repo: "parkr/other"}, expected: []string{}},
+		{text: "backoff", query: Query{Author: "parkr", Number: 1}, expected: []string{"patch"}},
+	} {
+		query := ParseQuery(test.text)
+		query.Author, query.Repo, query.Number, query.Since = test.query.Author, test.query.Repo, test.query.Number, test.query.Since
+		results := index.Search(query)
+
+		kinds := []string{}
+		for _, result := range results {
+			if result.Repo != "parkr/hello" || result.Number != 1 {
+				t.Errorf("%s: expected parkr/hello#1, got %s#%d", test.text, result.Repo, result.Number)
+			}
+			kinds = append(kinds, result.Kind)
+		}
+		if len(kinds) != len(test.expected) {
+			t.Errorf("%s %+v: expected %v, got %v", test.text, test.query, test.expected, kinds)
+			continue
+		}
+		for i := range kinds {
+			if kinds[i] != test.expected[i] {
+				t.Errorf("%s %+v: expected %v, got %v", test.text, test.query, test.expected, kinds)
+				break
+			}
+		}
+	}
+}
+
+func TestIndexSearchPages(t *testing.T) {
+	dir := t.TempDir()
+	state, err := LoadSyncState(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	pr := syncTestPullRequest(1, "abc", time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC))
+	pr.Title = github.String("Add retries")
+	pr.User = &github.User{Login: github.String("parkr")}
+	pr.CreatedAt = &github.Timestamp{Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	state.Record("parkr/hello", pr)
+	if err := state.Save(); err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+
+	page := "<html><style>.retries { color: red; }</style><body><p>Please use <em>exponential</em> backoff &amp; jitter.</p></body></html>"
+	if err := os.WriteFile(filepath.Join(dir, "1.html"), []byte(page), 0600); err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte("<p>backoff</p>"), 0600); err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "1.patch"), []byte("+func backoff(attempt int) time.Duration {\n"), 0600); err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+
+	index, err := BuildIndex(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+
+	results := index.Search(ParseQuery(`"exponential backoff"`))
+	if len(results) != 1 || results[0].Kind != "page" {
+		t.Fatalf("expected the page to match, got %+v", results)
+	}
+	if excerpt := results[0].Excerpt; excerpt != "Please use exponential backoff & jitter." {
+		t.Errorf("expected the excerpt to be the page's text, got %q", excerpt)
+	}
+	if len(index.Search(ParseQuery("color"))) != 0 {
+		t.Errorf("expected the page's stylesheet not to be indexed")
+	}
+
+	results = index.Search(Query{Terms: []string{"backoff"}, Author: "parkr", Repo: "parkr/hello"})
+	if len(results) != 2 {
+		t.Fatalf("expected the page and patch to be attributed to @parkr, got %+v", results)
+	}
+	for _, result := range results {
+		if result.Subject != "Add retries" || !result.Date.Equal(pr.GetCreatedAt().Time) {
+			t.Errorf("expected %s to be described by the sync state, got %+v", result.Kind, result.IndexedDocument)
+		}
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	for _, test := range []struct {
+		text, term, expected string
+	}{
+		{"first line\nUse Exponential backoff.", "exponential", "Use Exponential backoff."},
+		{"nothing here", "backoff", ""},
+		// Lowercasing these changes their length in bytes.
+		{strings.Repeat("Ⱥ", 100) + " Backoff", "backoff", strings.Repeat("Ⱥ", 29) + " Backoff"},
+		{"İstanbul İSTANBUL backoff", "backoff", "İstanbul İSTANBUL backoff"},
+		{"Retry with BACKOFF " + strings.Repeat("İ", 40), "backoff", "Retry with BACKOFF " + strings.Repeat("İ", 29)},
+	} {
+		if actual := excerpt(test.text, test.term); actual != test.expected {
+			t.Errorf("excerpt(%q, %q): expected %q, got %q", test.text, test.term, test.expected, actual)
+		}
+	}
+}
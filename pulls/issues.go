@@ -0,0 +1,279 @@
+package pulls
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v88/github"
+	"github.com/parkr/github-utils/gh"
+	"github.com/parkr/github-utils/search"
+)
+
+// Which issues to download. If Query is set, it's used as a search query
+// scoped to the repo, and the other fields are ignored.
+type IssueFilter struct {
+	// One of "open", "closed" or "all".
+	State    string
+	Labels   []string
+	Assignee string
+	Query    string
+}
+
+// Sends the repo's issues matching the filter to input, closing it when done.
+// Pull requests are skipped.
+func FetchIssues(client *gh.Client, repo string, filter IssueFilter, input chan *github.Issue) error {
+	defer close(input)
+
+	send := func(issue *github.Issue) error {
+		select {
+		case input <- issue:
+			return nil
+		case <-client.Context.Done():
+			return client.Context.Err()
+		}
+	}
+
+	if filter.Query != "" {
+		issues, err := search.SearchIssues(client, fmt.Sprintf("repo:%s is:issue %s", repo, filter.Query))
+		if err != nil {
+			return err
+		}
+		for _, issue := range issues {
+			issue := issue
+			if err := send(&issue); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	owner, name := splitRepo(repo)
+	opts := &github.IssueListByRepoOptions{
+		State:       filter.State,
+		Labels:      filter.Labels,
+		Assignee:    filter.Assignee,
+		Sort:        "created",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for {
+		issues, resp, err := client.Issues.ListByRepo(client.Context, owner, name, opts)
+		if err != nil {
+			log.Printf("error fetching issues for '%s': %+v", repo, err)
+			return err
+		}
+
+		for _, issue := range issues {
+			if issue.IsPullRequest() {
+				continue
+			}
+			if err := send(issue); err != nil {
+				return err
+			}
+		}
+
+		if resp.NextPage == 0 {
+			log.Printf("repo(%s): all issues fetched", repo)
+			break
+		}
+		opts.ListOptions.Page = resp.NextPage
+	}
+
+	return nil
+}
+
+// Writes each issue from input with opts.Writer, using a pool of
+// opts.Concurrency workers, sending the result of each to output. Output is
+// closed once input is closed and drained. Once client.Context is cancelled,
+// the remaining issues are drained from input without being written.
+func CacheIssuesLocally(client *gh.Client, opts Options, repo string, input <-chan *github.Issue, output chan<- OfflineStatusResponse) {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for issue := range input {
+				if client.Context.Err() != nil {
+					continue
+				}
+				output <- WriteIssue(client, opts.Writer, opts.OutputDir, repo, issue)
+			}
+		}()
+	}
+	wg.Wait()
+	close(output)
+}
+
+// Fetches the repo's issues matching the filter and writes them with
+// CacheIssuesLocally, passing each result to report, if it's given. Like
+// ArchivePullRequests, the error is from fetching the issues, or
+// client.Context being cancelled, and issues which fail to be written are
+// counted in the summary instead.
+func ArchiveIssues(client *gh.Client, opts Options, repo string, filter IssueFilter, report func(OfflineStatusResponse)) (CacheSummary, error) {
+	input := make(chan *github.Issue, 100)
+	output := make(chan OfflineStatusResponse)
+	fetchErr := make(chan error, 1)
+
+	go func() { fetchErr <- FetchIssues(client, repo, filter, input) }()
+	go CacheIssuesLocally(client, opts, repo, input, output)
+
+	summary := CacheSummary{}
+	for resp := range output {
+		summary.Add(resp)
+		if report != nil {
+			report(resp)
+		}
+	}
+
+	if err := <-fetchErr; err != nil {
+		return summary, err
+	}
+	return summary, client.Context.Err()
+}
+
+// Writes an issue's description, comments and timeline events as messages.
+func WriteIssue(client *gh.Client, writer MessageWriter, outputDir string, repo string, issue *github.Issue) OfflineStatusResponse {
+	filename := writer.Filename(outputDir, issue.GetNumber())
+
+	comments, err := IssueMessages(client, repo, issue)
+	if err != nil {
+		return OfflineStatusResponse{Success: false, Error: err, Filename: filename, Number: issue.GetNumber()}
+	}
+
+	filename, err = writer.WriteMessages(outputDir, issue.GetNumber(), comments)
+	if err != nil {
+		return OfflineStatusResponse{Success: false, Error: err, Filename: filename, Number: issue.GetNumber()}
+	}
+
+	return OfflineStatusResponse{Success: true, Filename: filename, Number: issue.GetNumber()}
+}
+
+// Fetches the issue's description, comments and timeline events as threaded
+// messages, sorted by when they were created.
+func IssueMessages(client *gh.Client, repo string, issue *github.Issue) (Comments, error) {
+	owner, name := splitRepo(repo)
+	to := localRecipient(client)
+
+	issueComment := Comment{
+		To:        to,
+		Name:      userName(issue.User),
+		Username:  issue.GetUser().GetLogin(),
+		Subject:   issue.GetTitle(),
+		Message:   issue.GetBody(),
+		CreatedAt: issue.GetCreatedAt().Time,
+		MessageID: issueMessageID(repo, issue.GetNumber(), "", 0),
+	}
+	comments := Comments{issueComment}
+
+	issueComments, err := GetPullRequestComments(client, owner, name, issue.GetNumber(), time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	for _, comment := range issueComments {
+		c := Comment{
+			To:        to,
+			Name:      userName(comment.User),
+			Username:  comment.GetUser().GetLogin(),
+			Subject:   "Re: " + issue.GetTitle(),
+			Message:   comment.GetBody(),
+			CreatedAt: comment.GetCreatedAt().Time,
+			MessageID: issueMessageID(repo, issue.GetNumber(), "c", comment.GetID()),
+		}
+		c.ReplyTo(issueComment)
+		comments = append(comments, c)
+	}
+
+	events, err := GetIssueTimeline(client, owner, name, issue.GetNumber())
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range events {
+		description := timelineEventDescription(event)
+		if description == "" {
+			continue
+		}
+		// Not every kind of event has an ID, but each has a unique time.
+		id := event.GetID()
+		if id == 0 {
+			id = event.GetCreatedAt().UnixNano()
+		}
+		c := Comment{
+			To:        to,
+			Name:      userName(event.Actor),
+			Username:  event.GetActor().GetLogin(),
+			Subject:   "Re: " + issue.GetTitle(),
+			Message:   description,
+			CreatedAt: event.GetCreatedAt().Time,
+			MessageID: issueMessageID(repo, issue.GetNumber(), "e", id),
+		}
+		c.ReplyTo(issueComment)
+		comments = append(comments, c)
+	}
+
+	sort.Stable(comments)
+
+	return comments, nil
+}
+
+func GetIssueTimeline(client *gh.Client, owner, repoName string, number int) ([]*github.Timeline, error) {
+	events, nwo := []*github.Timeline{}, owner+"/"+repoName
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		apiEvents, resp, err := client.Issues.ListIssueTimeline(client.Context, owner, repoName, number, opts)
+		if err != nil {
+			log.Printf("error fetching issue timeline for '%s': %+v", nwo, err)
+			return nil, err
+		}
+
+		events = append(events, apiEvents...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return events, nil
+}
+
+// Describes a timeline event, or returns an empty string if it isn't one
+// worth a message. Comments are fetched separately.
+func timelineEventDescription(event *github.Timeline) string {
+	switch event.GetEvent() {
+	case "closed":
+		if event.GetCommitID() != "" {
+			return fmt.Sprintf("Closed this in %s.", shortSHA(event.GetCommitID()))
+		}
+		return "Closed this."
+	case "reopened":
+		return "Reopened this."
+	case "referenced":
+		return fmt.Sprintf("Referenced this in commit %s.", shortSHA(event.GetCommitID()))
+	case "cross-referenced":
+		source := event.GetSource().GetIssue()
+		return fmt.Sprintf("Mentioned this in %s#%d: %s\n\n%s",
+			source.GetRepository().GetFullName(), source.GetNumber(), source.GetTitle(), source.GetHTMLURL())
+	case "labeled":
+		return fmt.Sprintf("Added the %q label.", event.GetLabel().GetName())
+	case "unlabeled":
+		return fmt.Sprintf("Removed the %q label.", event.GetLabel().GetName())
+	case "assigned":
+		return fmt.Sprintf("Assigned @%s.", event.GetAssignee().GetLogin())
+	case "unassigned":
+		return fmt.Sprintf("Unassigned @%s.", event.GetAssignee().GetLogin())
+	case "milestoned":
+		return fmt.Sprintf("Added this to the %s milestone.", event.GetMilestone().GetTitle())
+	case "demilestoned":
+		return fmt.Sprintf("Removed this from the %s milestone.", event.GetMilestone().GetTitle())
+	case "renamed":
+		return fmt.Sprintf("Changed the title from %q to %q.", event.GetRename().GetFrom(), event.GetRename().GetTo())
+	default:
+		return ""
+	}
+}
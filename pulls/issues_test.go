@@ -0,0 +1,120 @@
+package pulls
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v88/github"
+	"github.com/parkr/github-utils/gh/ghtest"
+)
+
+func TestIssueMessages(t *testing.T) {
+	client := ghtest.NewClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/user":
+			w.Write([]byte(`{"login": "parkr"}`))
+		case "/repos/parkr/hello/issues/1/comments":
+			w.Write([]byte(`[{"id": 10, "body": "Me too!", "user": {"login": "jekyllbot"}, "created_at": "2018-01-02T00:00:00Z"}]`))
+		case "/repos/parkr/hello/issues/1/timeline":
+			w.Write([]byte(`[
+				{"id": 20, "event": "labeled", "label": {"name": "bug"}, "actor": {"login": "parkr"}, "created_at": "2018-01-01T12:00:00Z"},
+				{"event": "commented", "actor": {"login": "jekyllbot"}, "created_at": "2018-01-02T00:00:00Z"},
+				{"id": 21, "event": "closed", "commit_id": "0123456789abcdef", "actor": {"login": "parkr"}, "created_at": "2018-01-03T00:00:00Z"}
+			]`))
+		default:
+			http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+		}
+	})
+
+	issue := &github.Issue{
+		Number:    github.Int(1),
+		Title:     github.String("It's broken"),
+		Body:      github.String("Please fix."),
+		State:     github.String("closed"),
+		User:      &github.User{Login: github.String("parkr")},
+		Labels:    []*github.Label{{Name: github.String("bug")}},
+		CreatedAt: &github.Timestamp{Time: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	comments, err := IssueMessages(client, "parkr/hello", issue)
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+
+	expected := []struct{ username, message, messageID string }{
+		{"parkr", "Please fix.", "<parkr/hello/issues/1@github.com>"},
+		{"parkr", `Added the "bug" label.`, "<parkr/hello/issues/1/e20@github.com>"},
+		{"jekyllbot", "Me too!", "<parkr/hello/issues/1/c10@github.com>"},
+		{"parkr", "Closed this in 0123456.", "<parkr/hello/issues/1/e21@github.com>"},
+	}
+	if len(comments) != len(expected) {
+		t.Fatalf("expected %d messages, got %d: %+v", len(expected), len(comments), comments)
+	}
+	for i, comment := range comments {
+		if comment.Username != expected[i].username || comment.Message != expected[i].message || comment.MessageID != expected[i].messageID {
+			t.Errorf("message %d: expected %+v, got %s %q %s", i, expected[i], comment.Username, comment.Message, comment.MessageID)
+		}
+		if i > 0 && comment.InReplyTo != comments[0].MessageID {
+			t.Errorf("message %d: expected a reply to the issue, got %q", i, comment.InReplyTo)
+		}
+	}
+}
+
+func TestTimelineEventDescription(t *testing.T) {
+	for _, test := range []struct {
+		event    *github.Timeline
+		expected string
+	}{
+		{&github.Timeline{Event: github.String("closed")}, "Closed this."},
+		{&github.Timeline{Event: github.String("closed"), CommitID: github.String("0123456789abcdef")}, "Closed this in 0123456."},
+		{&github.Timeline{Event: github.String("reopened")}, "Reopened this."},
+		{&github.Timeline{Event: github.String("referenced"), CommitID: github.String("0123456789abcdef")}, "Referenced this in commit 0123456."},
+		{&github.Timeline{Event: github.String("cross-referenced"), Source: &github.Source{Issue: &github.Issue{
+			Number:     github.Int(2),
+			Title:      github.String("Fix it"),
+			HTMLURL:    github.String("https://github.com/jekyll/jekyll/pull/2"),
+			Repository: &github.Repository{FullName: github.String("jekyll/jekyll")},
+		}}}, "Mentioned this in jekyll/jekyll#2: Fix it\n\nhttps://github.com/jekyll/jekyll/pull/2"},
+		{&github.Timeline{Event: github.String("labeled"), Label: &github.Label{Name: github.String("bug")}}, `Added the "bug" label.`},
+		{&github.Timeline{Event: github.String("unlabeled"), Label: &github.Label{Name: github.String("bug")}}, `Removed the "bug" label.`},
+		{&github.Timeline{Event: github.String("assigned"), Assignee: &github.User{Login: github.String("parkr")}}, "Assigned @parkr."},
+		{&github.Timeline{Event: github.String("unassigned"), Assignee: &github.User{Login: github.String("parkr")}}, "Unassigned @parkr."},
+		{&github.Timeline{Event: github.String("milestoned"), Milestone: &github.Milestone{Title: github.String("v4.0")}}, "Added this to the v4.0 milestone."},
+		{&github.Timeline{Event: github.String("demilestoned"), Milestone: &github.Milestone{Title: github.String("v4.0")}}, "Removed this from the v4.0 milestone."},
+		{&github.Timeline{Event: github.String("renamed"), Rename: &github.Rename{From: github.String("Bug"), To: github.String("It's broken")}}, `Changed the title from "Bug" to "It's broken".`},
+		{&github.Timeline{Event: github.String("commented")}, ""},
+		{&github.Timeline{Event: github.String("subscribed")}, ""},
+	} {
+		if actual := timelineEventDescription(test.event); actual != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.event.GetEvent(), test.expected, actual)
+		}
+	}
+}
+
+func TestArchiveIssuesCountsFailures(t *testing.T) {
+	client := ghtest.NewClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/user":
+			w.Write([]byte(`{"login": "parkr"}`))
+		case "/repos/parkr/hello/issues":
+			w.Write([]byte(`[{"number": 1, "title": "Works"}, {"number": 2, "title": "Fails"}]`))
+		case "/repos/parkr/hello/issues/1/comments", "/repos/parkr/hello/issues/1/timeline":
+			w.Write([]byte(`[]`))
+		default:
+			http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+		}
+	})
+
+	writer, err := NewMessageWriter("mbox")
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	opts := Options{OutputDir: t.TempDir(), Writer: writer, Concurrency: 2}
+	summary, err := ArchiveIssues(client, opts, "parkr/hello", IssueFilter{State: "open"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	if summary.Succeeded != 1 || summary.Failed != 1 {
+		t.Errorf("expected 1 issue written and 1 failed, got %s", summary)
+	}
+}
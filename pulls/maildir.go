@@ -0,0 +1,85 @@
+package pulls
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Writes a pull request's messages to a Maildir named <number>, one file per
+// message under cur/. Messages are marked as seen.
+type MaildirWriter struct{}
+
+func (MaildirWriter) Filename(outputDir string, number int) string {
+	return filepath.Join(outputDir, fmt.Sprintf("%d", number))
+}
+
+func (w MaildirWriter) WriteMessages(outputDir string, number int, comments Comments) (string, error) {
+	dir := w.Filename(outputDir, number)
+	for _, subdir := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, subdir), 0700); err != nil {
+			return dir, err
+		}
+	}
+
+	written, err := maildirUniqueNames(dir)
+	if err != nil {
+		return dir, err
+	}
+
+	for _, comment := range comments {
+		uniqueName := maildirUniqueName(comment)
+		if written[uniqueName] {
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := writeMessageHeaders(comment, &buf); err != nil {
+			return dir, err
+		}
+		buf.WriteString(comment.Message + "\n")
+
+		// Deliver via tmp/ so readers never see a partially-written message.
+		tmpFilename := filepath.Join(dir, "tmp", uniqueName)
+		if err := os.WriteFile(tmpFilename, buf.Bytes(), 0600); err != nil {
+			return dir, err
+		}
+		if err := os.Rename(tmpFilename, filepath.Join(dir, "cur", uniqueName+":2,S")); err != nil {
+			return dir, err
+		}
+		written[uniqueName] = true
+	}
+
+	return dir, nil
+}
+
+// Returns a filename for the comment which is stable across runs, so that it
+// can be used to tell whether the comment has already been written. Maildir
+// names start with a timestamp so they sort chronologically.
+func maildirUniqueName(comment Comment) string {
+	key := comment.MessageID
+	if key == "" {
+		key = comment.CreatedAt.String() + comment.Username + comment.Message
+	}
+	return fmt.Sprintf("%d.%x.github-utils", comment.CreatedAt.Unix(), sha1.Sum([]byte(key)))
+}
+
+// Returns the unique names of the messages already in the Maildir, without
+// the info suffix mail clients change when updating flags.
+func maildirUniqueNames(dir string) (map[string]bool, error) {
+	names := map[string]bool{}
+	for _, subdir := range []string{"new", "cur"} {
+		entries, err := os.ReadDir(filepath.Join(dir, subdir))
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			name, _, _ := strings.Cut(entry.Name(), ":")
+			names[name] = true
+		}
+	}
+	return names, nil
+}
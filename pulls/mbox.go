@@ -0,0 +1,137 @@
+package pulls
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Writes all of a pull request's messages to a single <number>.mbox file.
+type MboxWriter struct{}
+
+func (MboxWriter) Filename(outputDir string, number int) string {
+	return filepath.Join(outputDir, fmt.Sprintf("%d.mbox", number))
+}
+
+func (w MboxWriter) WriteMessages(outputDir string, number int, comments Comments) (string, error) {
+	filename := w.Filename(outputDir, number)
+
+	written, err := mboxMessageIDs(filename)
+	if err != nil {
+		return filename, err
+	}
+
+	f, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return filename, err
+	}
+	defer f.Close()
+
+	for _, comment := range comments {
+		if comment.MessageID != "" && written[comment.MessageID] {
+			continue
+		}
+		if err := writeCommentAsMbox(comment, f); err != nil {
+			return filename, err
+		}
+	}
+
+	return filename, f.Close()
+}
+
+// Returns the Message-IDs of the messages already in the mbox file, if any.
+func mboxMessageIDs(filename string) (map[string]bool, error) {
+	messageIDs := map[string]bool{}
+
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return messageIDs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// Only look at headers: a body could quote a Message-ID header.
+	inHeaders := false
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "From "):
+			inHeaders = true
+		case line == "":
+			inHeaders = false
+		case inHeaders && strings.HasPrefix(line, "Message-ID: "):
+			messageIDs[strings.TrimPrefix(line, "Message-ID: ")] = true
+		}
+	}
+	return messageIDs, scanner.Err()
+}
+
+// Lines in a message body which would be mistaken for the start of a new
+// message. Per mboxrd, they and any already-quoted variants get another ">".
+var mboxFromLine = regexp.MustCompile(`(?m)^(>*From )`)
+
+// Write a comment to the buffer in mbox format.
+func writeCommentAsMbox(comment Comment, buf io.Writer) error {
+	mailtime := comment.CreatedAt.Format("Mon Jan 2 15:04:05 2006")
+	fmt.Fprintf(buf, "From %s %s\n", comment.Email(), mailtime)
+	if err := writeMessageHeaders(comment, buf); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(buf, "%s\n\n", mboxFromLine.ReplaceAllString(comment.Message, ">$1"))
+	return err
+}
+
+// Splits an mbox written by MboxWriter into its messages, undoing the
+// mboxrd quoting of "From " lines in their bodies.
+func readMbox(r io.Reader) ([]*mail.Message, error) {
+	messages := []*mail.Message{}
+	var message *bytes.Buffer
+	flush := func() error {
+		if message == nil {
+			return nil
+		}
+		m, err := mail.ReadMessage(message)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, m)
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "From ") {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			message = &bytes.Buffer{}
+			continue
+		}
+		if message == nil {
+			continue
+		}
+		if strings.HasPrefix(line, ">") && mboxFromLine.MatchString(line[1:]) {
+			line = line[1:]
+		}
+		message.WriteString(line + "\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
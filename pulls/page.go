@@ -0,0 +1,337 @@
+package pulls
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v88/github"
+	"github.com/parkr/github-utils/gh"
+)
+
+// Everything shown on a pull request's page.
+type PullRequestPage struct {
+	Repo        string
+	PullRequest *github.PullRequest
+	// One of "open", "closed" or "merged".
+	State string
+	// The name of the patch file, relative to the page.
+	PatchFilename string
+
+	// The comments and reviews, oldest first. Line comments are shown inline
+	// in Files instead, except for those on lines no longer in the diff,
+	// which are threaded in Outdated.
+	Conversation Comments
+	Files        []*DiffFile
+	Outdated     []Comments
+}
+
+// A file changed by a pull request, with line comments threaded under the
+// line they refer to.
+type DiffFile struct {
+	Filename             string
+	Status               string
+	Additions, Deletions int
+	Lines                []*DiffLine
+
+	// A Markdown code fence longer than any run of backticks in the diff.
+	Fence string
+}
+
+// A single line of a diff. Kind is one of "hunk", "add", "del", "context",
+// or "meta" for lines like "\ No newline at end of file".
+type DiffLine struct {
+	Kind             string
+	Text             string
+	OldLine, NewLine int
+	Comments         Comments
+}
+
+var hunkHeaderPattern = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+
+// Fetches the pull request's comments, reviews and changed files, and
+// arranges them into a page. Unlike PullRequestMessages, everything is
+// fetched, since the page is rewritten each time.
+func PullRequestPageData(client *gh.Client, repo string, pr *github.PullRequest, patchFilename string) (*PullRequestPage, error) {
+	owner, name := splitRepo(repo)
+	page := &PullRequestPage{
+		Repo:          repo,
+		PullRequest:   pr,
+		State:         pullRequestState(pr),
+		PatchFilename: patchFilename,
+	}
+
+	issueComments, err := GetPullRequestComments(client, owner, name, pr.GetNumber(), time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	for _, comment := range issueComments {
+		page.Conversation = append(page.Conversation, Comment{
+			Name:      userName(comment.User),
+			Username:  comment.GetUser().GetLogin(),
+			Message:   comment.GetBody(),
+			CreatedAt: comment.GetCreatedAt().Time,
+		})
+	}
+
+	reviews, err := GetPullRequestReviews(client, owner, name, pr.GetNumber())
+	if err != nil {
+		return nil, err
+	}
+	for _, review := range reviews {
+		if review.GetState() == "PENDING" || (review.GetState() == "COMMENTED" && review.GetBody() == "") {
+			continue
+		}
+		page.Conversation = append(page.Conversation, Comment{
+			Name:      userName(review.User),
+			Username:  review.GetUser().GetLogin(),
+			Message:   reviewBody(review),
+			CreatedAt: review.GetSubmittedAt().Time,
+		})
+	}
+	sort.Stable(page.Conversation)
+
+	files, err := GetPullRequestFiles(client, owner, name, pr.GetNumber())
+	if err != nil {
+		return nil, err
+	}
+	lineComments, err := GetPullRequestLineComments(client, owner, name, pr.GetNumber(), time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	page.Files, page.Outdated = diffFiles(files, lineComments)
+
+	return page, nil
+}
+
+// Parses each file's diff, and threads the line comments under the lines
+// they refer to. Comments on lines which are no longer in the diff are
+// returned separately, one thread per slice. Comments must be oldest first.
+func diffFiles(files []*github.CommitFile, comments []*github.PullRequestComment) ([]*DiffFile, []Comments) {
+	diffs, byPath := []*DiffFile{}, map[string]*DiffFile{}
+	for _, file := range files {
+		diff := parseDiffFile(file)
+		diffs = append(diffs, diff)
+		byPath[diff.Filename] = diff
+	}
+
+	threads, outdatedThreads := map[int64]*DiffLine{}, map[int64]int{}
+	outdated := []Comments{}
+	for _, comment := range comments {
+		c := Comment{
+			Name:      userName(comment.User),
+			Username:  comment.GetUser().GetLogin(),
+			Message:   comment.GetBody(),
+			CreatedAt: comment.GetCreatedAt().Time,
+		}
+
+		if parent := comment.GetInReplyTo(); parent != 0 {
+			if line, ok := threads[parent]; ok {
+				line.Comments = append(line.Comments, c)
+				threads[comment.GetID()] = line
+				continue
+			}
+			if i, ok := outdatedThreads[parent]; ok {
+				outdated[i] = append(outdated[i], c)
+				outdatedThreads[comment.GetID()] = i
+				continue
+			}
+		}
+
+		var line *DiffLine
+		if diff, ok := byPath[comment.GetPath()]; ok {
+			line = diff.line(comment.GetSide(), comment.GetLine())
+		}
+		if line == nil {
+			// Show the hunk it was made on, since it's not in the diff.
+			c.Message = unifiedCommentBody(comment)
+			outdatedThreads[comment.GetID()] = len(outdated)
+			outdated = append(outdated, Comments{c})
+			continue
+		}
+		line.Comments = append(line.Comments, c)
+		threads[comment.GetID()] = line
+	}
+
+	return diffs, outdated
+}
+
+// Splits a file's patch into lines, numbered as in the old and new files.
+func parseDiffFile(file *github.CommitFile) *DiffFile {
+	diff := &DiffFile{
+		Filename:  file.GetFilename(),
+		Status:    file.GetStatus(),
+		Additions: file.GetAdditions(),
+		Deletions: file.GetDeletions(),
+		Fence:     markdownFence(file.GetPatch()),
+	}
+	if file.GetPatch() == "" {
+		return diff
+	}
+
+	var oldLine, newLine int
+	for _, text := range strings.Split(strings.TrimSuffix(file.GetPatch(), "\n"), "\n") {
+		line := &DiffLine{Text: text}
+		switch {
+		case strings.HasPrefix(text, "@@"):
+			line.Kind = "hunk"
+			if matches := hunkHeaderPattern.FindStringSubmatch(text); matches != nil {
+				oldLine, _ = strconv.Atoi(matches[1])
+				newLine, _ = strconv.Atoi(matches[2])
+			}
+		case strings.HasPrefix(text, "+"):
+			line.Kind, line.NewLine = "add", newLine
+			newLine++
+		case strings.HasPrefix(text, "-"):
+			line.Kind, line.OldLine = "del", oldLine
+			oldLine++
+		case strings.HasPrefix(text, `\`):
+			line.Kind = "meta"
+		default:
+			line.Kind, line.OldLine, line.NewLine = "context", oldLine, newLine
+			oldLine++
+			newLine++
+		}
+		diff.Lines = append(diff.Lines, line)
+	}
+	return diff
+}
+
+// Returns the line with the given number on the given side of the diff
+// ("LEFT" for the old file, otherwise the new one), or nil if it isn't there.
+func (d *DiffFile) line(side string, number int) *DiffLine {
+	if number == 0 {
+		return nil
+	}
+	for _, line := range d.Lines {
+		switch {
+		case side == "LEFT" && line.OldLine == number && line.Kind != "add":
+			return line
+		case side != "LEFT" && line.NewLine == number && line.Kind != "del":
+			return line
+		}
+	}
+	return nil
+}
+
+// Returns a code fence which can't be closed by anything in contents.
+func markdownFence(contents string) string {
+	longest, run := 0, 0
+	for _, r := range contents {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
+
+// A pull request's entry on the repo's index page.
+type IndexEntry struct {
+	Number    int
+	Title     string
+	Author    string
+	State     string
+	UpdatedAt time.Time
+	// The name of the pull request's page, relative to the index.
+	Filename string
+}
+
+// Everything shown on a repo's index page.
+type IndexPage struct {
+	Repo         string
+	GeneratedAt  time.Time
+	PullRequests []IndexEntry
+}
+
+// The names of the available page formats, for use with NewPageRenderer.
+var PageFormats = []string{"markdown", "html"}
+
+// The part of text/template and html/template a PageRenderer uses.
+type pageTemplate interface {
+	Execute(w io.Writer, data any) error
+}
+
+// A PageRenderer writes pull requests as self-contained pages, which can be
+// read without a mail client, and an index page linking to them.
+type PageRenderer struct {
+	extension          string
+	pullRequest, index pageTemplate
+}
+
+// Returns the PageRenderer for the named format.
+func NewPageRenderer(format string) (*PageRenderer, error) {
+	switch format {
+	case "markdown":
+		return &PageRenderer{extension: "md", pullRequest: markdownPullRequestTmpl, index: markdownIndexTmpl}, nil
+	case "html":
+		return &PageRenderer{extension: "html", pullRequest: htmlPullRequestTmpl, index: htmlIndexTmpl}, nil
+	default:
+		return nil, fmt.Errorf("unknown page format %q, expected one of: %s", format, strings.Join(PageFormats, ", "))
+	}
+}
+
+// Returns the path the pull request's page is written to.
+func (r *PageRenderer) Filename(outputDir string, number int) string {
+	return filepath.Join(outputDir, fmt.Sprintf("%d.%s", number, r.extension))
+}
+
+// Returns the path the index page is written to.
+func (r *PageRenderer) IndexFilename(outputDir string) string {
+	return filepath.Join(outputDir, "index."+r.extension)
+}
+
+// Renders the page, replacing any previous one.
+func (r *PageRenderer) WritePage(outputDir string, page *PullRequestPage) (string, error) {
+	filename := r.Filename(outputDir, page.PullRequest.GetNumber())
+	return filename, writeFileAtomically(filename, func(f *os.File) error {
+		return r.pullRequest.Execute(f, page)
+	})
+}
+
+// Renders an index page linking to the pages of every pull request in the
+// sync state which has one, newest first, replacing any previous one. Pull
+// requests not listed in this sync, like those since closed, are included.
+func (r *PageRenderer) WriteIndex(outputDir, repo string, state *SyncState) (string, error) {
+	index := &IndexPage{Repo: repo, GeneratedAt: time.Now()}
+	for number, prState := range state.PullRequests(repo) {
+		filename := r.Filename(outputDir, number)
+		if _, err := os.Stat(filename); err != nil {
+			continue
+		}
+		index.PullRequests = append(index.PullRequests, IndexEntry{
+			Number:    number,
+			Title:     prState.Title,
+			Author:    prState.Author,
+			State:     prState.State,
+			UpdatedAt: prState.UpdatedAt,
+			Filename:  filepath.Base(filename),
+		})
+	}
+	sort.Slice(index.PullRequests, func(i, j int) bool {
+		return index.PullRequests[i].Number > index.PullRequests[j].Number
+	})
+
+	filename := r.IndexFilename(outputDir)
+	return filename, writeFileAtomically(filename, func(f *os.File) error {
+		return r.index.Execute(f, index)
+	})
+}
+
+// Fetches the pull request's page data and renders it with the renderer.
+func WritePageFile(client *gh.Client, opts Options, repo string, pr *github.PullRequest) (string, error) {
+	patchFilename := filepath.Base(opts.PatchFilename(pr.GetNumber()))
+	page, err := PullRequestPageData(client, repo, pr, patchFilename)
+	if err != nil {
+		return opts.Renderer.Filename(opts.OutputDir, pr.GetNumber()), err
+	}
+	return opts.Renderer.WritePage(opts.OutputDir, page)
+}
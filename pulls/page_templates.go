@@ -0,0 +1,143 @@
+package pulls
+
+import (
+	htmltemplate "html/template"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var pageFuncs = map[string]any{
+	"date": func(t time.Time) string { return t.Format("2006-01-02 15:04") },
+	// Quotes text as a Markdown block quote.
+	"quote": func(text string) string {
+		lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight("> "+line, " ")
+		}
+		return strings.Join(lines, "\n")
+	},
+	// Escapes text for a Markdown table cell.
+	"cell": func(text string) string {
+		return strings.ReplaceAll(strings.ReplaceAll(text, "|", `\|`), "\n", " ")
+	},
+}
+
+var markdownPullRequestTmpl = template.Must(template.New("pullRequest").Funcs(pageFuncs).Parse(`
+{{- define "comment"}}> **{{.Name}}** (@{{.Username}}) on {{date .CreatedAt}}:
+>
+{{quote .Message}}
+
+{{end -}}
+# {{.PullRequest.GetTitle}} (#{{.PullRequest.GetNumber}})
+
+{{.Repo}} · {{.State}} · @{{.PullRequest.GetUser.GetLogin}} wants to merge ` + "`{{.PullRequest.GetHead.GetLabel}}` into `{{.PullRequest.GetBase.GetRef}}`" + ` · [patch]({{.PatchFilename}}) · [on GitHub]({{.PullRequest.GetHTMLURL}})
+
+{{.PullRequest.GetBody}}
+
+## Conversation
+
+{{range .Conversation}}{{template "comment" .}}{{else}}No comments.
+
+{{end -}}
+## Changes
+{{range $file := .Files}}
+### ` + "`{{$file.Filename}}`" + ` ({{$file.Status}}, +{{$file.Additions}} -{{$file.Deletions}})
+
+{{if $file.Lines -}}
+{{$file.Fence}}diff
+{{range $file.Lines}}{{.Text}}
+{{if .Comments}}{{$file.Fence}}
+
+{{range .Comments}}{{template "comment" .}}{{end -}}
+{{$file.Fence}}diff
+{{end}}{{end}}{{$file.Fence}}
+{{else -}}
+Binary file, or too large to display.
+{{end}}{{end}}
+{{- if .Outdated}}
+## Outdated comments
+{{range .Outdated}}
+{{range .}}{{template "comment" .}}{{end}}{{end}}{{end}}`))
+
+var markdownIndexTmpl = template.Must(template.New("index").Funcs(pageFuncs).Parse(`# Pull requests in {{.Repo}}
+
+Downloaded {{date .GeneratedAt}}.
+
+| # | Title | Author | State | Updated |
+|---|-------|--------|-------|---------|
+{{range .PullRequests}}| [#{{.Number}}]({{.Filename}}) | {{cell .Title}} | @{{.Author}} | {{.State}} | {{date .UpdatedAt}} |
+{{end}}`))
+
+const htmlPageStyle = `
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; max-width: 980px; margin: 2em auto; color: #1f2328; }
+.meta { color: #59636e; }
+.comment { border: 1px solid #d1d9e0; border-radius: 6px; margin: 1em 0; }
+.comment header { background: #f6f8fa; border-bottom: 1px solid #d1d9e0; padding: 0.5em 1em; }
+.comment .body { padding: 0.5em 1em; white-space: pre-wrap; }
+table.diff { border-collapse: collapse; width: 100%; font-family: ui-monospace, Menlo, Consolas, monospace; font-size: 12px; }
+table.diff td { padding: 0 0.5em; vertical-align: top; }
+table.diff td.num { color: #59636e; text-align: right; width: 1%; user-select: none; }
+table.diff td.text { white-space: pre-wrap; word-break: break-all; }
+tr.add { background: #dafbe1; }
+tr.del { background: #ffebe9; }
+tr.hunk, tr.meta { background: #ddf4ff; color: #59636e; }
+tr.comments td { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; font-size: 14px; padding: 0 1em; }
+table.index { border-collapse: collapse; }
+table.index td, table.index th { border-bottom: 1px solid #d1d9e0; padding: 0.25em 0.75em; text-align: left; }
+</style>`
+
+var htmlPullRequestTmpl = htmltemplate.Must(htmltemplate.New("pullRequest").Funcs(pageFuncs).Parse(`
+{{- define "comment"}}<div class="comment">
+<header><strong>{{.Name}}</strong> (@{{.Username}}) on {{date .CreatedAt}}</header>
+<div class="body">{{.Message}}</div>
+</div>
+{{end -}}
+<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.PullRequest.GetTitle}} (#{{.PullRequest.GetNumber}})</title>
+` + htmlPageStyle + `
+</head>
+<body>
+<h1>{{.PullRequest.GetTitle}} (#{{.PullRequest.GetNumber}})</h1>
+<p class="meta">{{.Repo}} · {{.State}} · @{{.PullRequest.GetUser.GetLogin}} wants to merge <code>{{.PullRequest.GetHead.GetLabel}}</code> into <code>{{.PullRequest.GetBase.GetRef}}</code> · <a href="{{.PatchFilename}}">patch</a> · <a href="{{.PullRequest.GetHTMLURL}}">on GitHub</a> · <a href="index.html">all pull requests</a></p>
+<div class="comment"><header><strong>{{.PullRequest.GetUser.GetLogin}}</strong> on {{date .PullRequest.GetCreatedAt.Time}}</header><div class="body">{{.PullRequest.GetBody}}</div></div>
+
+<h2>Conversation</h2>
+{{range .Conversation}}{{template "comment" .}}{{else}}<p>No comments.</p>{{end}}
+
+<h2>Changes</h2>
+{{range .Files}}
+<h3><code>{{.Filename}}</code> <span class="meta">({{.Status}}, +{{.Additions}} -{{.Deletions}})</span></h3>
+{{if .Lines}}<table class="diff">
+{{range .Lines}}<tr class="{{.Kind}}"><td class="num">{{if .OldLine}}{{.OldLine}}{{end}}</td><td class="num">{{if .NewLine}}{{.NewLine}}{{end}}</td><td class="text">{{.Text}}</td></tr>
+{{if .Comments}}<tr class="comments"><td colspan="3">{{range .Comments}}{{template "comment" .}}{{end}}</td></tr>
+{{end}}{{end}}</table>
+{{else}}<p>Binary file, or too large to display.</p>
+{{end}}{{end}}
+{{if .Outdated}}<h2>Outdated comments</h2>
+{{range .Outdated}}{{range .}}{{template "comment" .}}{{end}}{{end}}{{end}}
+</body>
+</html>
+`))
+
+var htmlIndexTmpl = htmltemplate.Must(htmltemplate.New("index").Funcs(pageFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Pull requests in {{.Repo}}</title>
+` + htmlPageStyle + `
+</head>
+<body>
+<h1>Pull requests in {{.Repo}}</h1>
+<p class="meta">Downloaded {{date .GeneratedAt}}.</p>
+<table class="index">
+<tr><th>#</th><th>Title</th><th>Author</th><th>State</th><th>Updated</th></tr>
+{{range .PullRequests}}<tr><td><a href="{{.Filename}}">#{{.Number}}</a></td><td>{{.Title}}</td><td>@{{.Author}}</td><td>{{.State}}</td><td>{{date .UpdatedAt}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
@@ -0,0 +1,139 @@
+package pulls
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v88/github"
+)
+
+func TestDiffFilesThreadsLineComments(t *testing.T) {
+	files := []*github.CommitFile{{
+		Filename: github.String("hello.go"),
+		Status:   github.String("modified"),
+		Patch:    github.String("@@ -1,3 +1,3 @@\n package main\n-var greeting = \"hi\"\n+var greeting = \"hello\"\n func main() {}"),
+	}}
+	comment := func(id, inReplyTo int64, side string, line int, body string) *github.PullRequestComment {
+		return &github.PullRequestComment{
+			ID:        github.Int64(id),
+			InReplyTo: github.Int64(inReplyTo),
+			Path:      github.String("hello.go"),
+			Side:      github.String(side),
+			Line:      github.Int(line),
+			DiffHunk:  github.String("@@ -1,3 +1,3 @@"),
+			Body:      github.String(body),
+			User:      &github.User{Login: github.String("parkr")},
+			CreatedAt: &github.Timestamp{Time: time.Unix(id, 0)},
+		}
+	}
+	comments := []*github.PullRequestComment{
+		comment(1, 0, "RIGHT", 2, "Nicer."),
+		comment(2, 0, "LEFT", 2, "Was this used?"),
+		comment(3, 1, "", 0, "Thanks!"),
+		comment(4, 0, "RIGHT", 0, "Outdated."),
+		comment(5, 4, "", 0, "Indeed."),
+	}
+
+	diffs, outdated := diffFiles(files, comments)
+	if len(diffs) != 1 || len(diffs[0].Lines) != 5 {
+		t.Fatalf("expected one file with 5 lines, got %+v", diffs)
+	}
+
+	for i, expected := range []struct {
+		kind             string
+		oldLine, newLine int
+		comments         []string
+	}{
+		{"hunk", 0, 0, nil},
+		{"context", 1, 1, nil},
+		{"del", 2, 0, []string{"Was this used?"}},
+		{"add", 0, 2, []string{"Nicer.", "Thanks!"}},
+		{"context", 3, 3, nil},
+	} {
+		line := diffs[0].Lines[i]
+		if line.Kind != expected.kind || line.OldLine != expected.oldLine || line.NewLine != expected.newLine {
+			t.Errorf("line %d: expected %s %d/%d, got %s %d/%d", i, expected.kind, expected.oldLine, expected.newLine, line.Kind, line.OldLine, line.NewLine)
+		}
+		messages := []string{}
+		for _, c := range line.Comments {
+			messages = append(messages, c.Message)
+		}
+		if strings.Join(messages, "|") != strings.Join(expected.comments, "|") {
+			t.Errorf("line %d: expected comments %q, got %q", i, expected.comments, messages)
+		}
+	}
+
+	if len(outdated) != 1 || len(outdated[0]) != 2 || outdated[0][1].Message != "Indeed." {
+		t.Errorf("expected one outdated thread of two comments, got %+v", outdated)
+	}
+
+	for _, format := range PageFormats {
+		renderer, err := NewPageRenderer(format)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %+v", format, err)
+		}
+		page := &PullRequestPage{
+			Repo:        "parkr/hello",
+			PullRequest: &github.PullRequest{Number: github.Int(1), Title: github.String("Say hello")},
+			Files:       diffs,
+			Outdated:    outdated,
+		}
+		filename, err := renderer.WritePage(t.TempDir(), page)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %+v", format, err)
+		}
+		contents, _ := os.ReadFile(filename)
+		reply, next := strings.Index(string(contents), "Thanks!"), strings.Index(string(contents), "func main")
+		if reply == -1 || reply > next {
+			t.Errorf("%s: expected the reply after the line it comments on, got:\n%s", format, contents)
+		}
+	}
+}
+
+func TestWriteIndexListsEveryPage(t *testing.T) {
+	dir := t.TempDir()
+	state, err := LoadSyncState(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	updatedAt := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	for number, title := range map[int]string{1: "Listed this time", 2: "Closed since", 3: "Never rendered"} {
+		pr := syncTestPullRequest(number, "abc", updatedAt)
+		pr.Title = github.String(title)
+		pr.User = &github.User{Login: github.String("parkr")}
+		state.Record("parkr/hello", pr)
+	}
+
+	renderer, err := NewPageRenderer("markdown")
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	for _, number := range []int{1, 2} {
+		if err := os.WriteFile(renderer.Filename(dir, number), []byte("page"), 0644); err != nil {
+			t.Fatalf("unexpected error: %+v", err)
+		}
+	}
+
+	filename, err := renderer.WriteIndex(dir, "parkr/hello", state)
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	index := string(contents)
+	for _, expected := range []string{"| [#2](2.md) | Closed since | @parkr |", "| [#1](1.md) | Listed this time | @parkr |"} {
+		if !strings.Contains(index, expected) {
+			t.Errorf("expected the index to contain %q, got:\n%s", expected, index)
+		}
+	}
+	if strings.Contains(index, "Never rendered") {
+		t.Errorf("expected pull requests without a page to be left out, got:\n%s", index)
+	}
+	if strings.Index(index, "#2") > strings.Index(index, "#1") {
+		t.Errorf("expected the newest pull request first, got:\n%s", index)
+	}
+}
@@ -0,0 +1,174 @@
+package pulls
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/google/go-github/v88/github"
+	"github.com/parkr/github-utils/gh"
+)
+
+// The largest patch written when Options.MaxPatchSize isn't set: 50 MiB.
+const DefaultMaxPatchSize = 50 << 20
+
+// Returned by WritePatchFile for patches over the size limit.
+var ErrPatchTooLarge = errors.New("patch is over the size limit")
+
+// The media types the API serves raw pull requests in.
+var patchMediaTypes = map[github.RawType]string{
+	github.Diff:  "application/vnd.github.v3.diff",
+	github.Patch: "application/vnd.github.v3.patch",
+}
+
+// Returns the name of the file the pull request's patch is written to:
+// <prNumber>.patch, or <prNumber>.diff when writing diffs.
+func (o Options) PatchFilename(number int) string {
+	extension := "patch"
+	if o.PatchType == github.Diff {
+		extension = "diff"
+	}
+	return filepath.Join(o.OutputDir, fmt.Sprintf("%d.%s", number, extension))
+}
+
+// Returns the directory snapshots of the files the pull request touches are
+// written to: <prNumber>.files.
+func (o Options) SnapshotDirname(number int) string {
+	return filepath.Join(o.OutputDir, fmt.Sprintf("%d.files", number))
+}
+
+// Downloads the pull request as a patch (or diff) through the API, so it
+// works for private repos, and writes it to a local file. Patches larger than
+// the size limit aren't written. The previous patch is left in place if the
+// download fails.
+func WritePatchFile(client *gh.Client, opts Options, pr *github.PullRequest) (string, error) {
+	patchFilename := opts.PatchFilename(pr.GetNumber())
+	owner, name := splitRepo(pr.GetBase().GetRepo().GetFullName())
+
+	patchType := opts.PatchType
+	if patchType == 0 {
+		patchType = github.Patch
+	}
+	maxSize := opts.MaxPatchSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxPatchSize
+	}
+
+	u := fmt.Sprintf("repos/%s/%s/pulls/%d", owner, name, pr.GetNumber())
+	req, err := client.NewRequest(client.Context, "GET", u, nil)
+	if err != nil {
+		return patchFilename, err
+	}
+	req.Header.Set("Accept", patchMediaTypes[patchType])
+
+	// BareDo returns an error for error statuses, but leaves the body to us.
+	resp, err := client.BareDo(req)
+	if err != nil {
+		return patchFilename, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return patchFilename, fmt.Errorf("fetching patch for #%d: unexpected status %s", pr.GetNumber(), resp.Status)
+	}
+	if resp.ContentLength > maxSize {
+		return patchFilename, fmt.Errorf("patch for #%d is %d bytes, over the %d byte limit: %w", pr.GetNumber(), resp.ContentLength, maxSize, ErrPatchTooLarge)
+	}
+
+	return patchFilename, writeFileAtomically(patchFilename, func(f *os.File) error {
+		n, err := io.Copy(f, io.LimitReader(resp.Body, maxSize+1))
+		if err != nil {
+			return err
+		}
+		if n > maxSize {
+			return fmt.Errorf("patch for #%d is over the %d byte limit: %w", pr.GetNumber(), maxSize, ErrPatchTooLarge)
+		}
+		return nil
+	})
+}
+
+// Writes the full contents of every file the pull request adds or modifies,
+// as of its head commit, into its snapshot directory, so they can be read in
+// context offline. Any previous snapshot is replaced.
+func WriteSnapshot(client *gh.Client, opts Options, pr *github.PullRequest) (string, error) {
+	dir := opts.SnapshotDirname(pr.GetNumber())
+	owner, name := splitRepo(pr.GetBase().GetRepo().GetFullName())
+
+	files, err := GetPullRequestFiles(client, owner, name, pr.GetNumber())
+	if err != nil {
+		return dir, err
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return dir, err
+	}
+	for _, file := range files {
+		if file.GetStatus() == "removed" {
+			continue
+		}
+		// Paths come from the API, but shouldn't be trusted to stay put.
+		path := filepath.FromSlash(file.GetFilename())
+		if !filepath.IsLocal(path) {
+			return dir, fmt.Errorf("refusing to write %q outside of %s", file.GetFilename(), dir)
+		}
+
+		// The head commit's blobs are reachable from the base repo, even
+		// when the pull request comes from a fork.
+		contents, _, err := client.Git.GetBlobRaw(client.Context, owner, name, file.GetSHA())
+		if err != nil {
+			return dir, fmt.Errorf("fetching %s: %w", file.GetFilename(), err)
+		}
+
+		filename := filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+			return dir, err
+		}
+		if err := os.WriteFile(filename, contents, 0600); err != nil {
+			return dir, err
+		}
+	}
+	return dir, nil
+}
+
+// Lists the files the pull request changes. The API lists at most 3000.
+func GetPullRequestFiles(client *gh.Client, owner, repoName string, number int) ([]*github.CommitFile, error) {
+	files := []*github.CommitFile{}
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		apiFiles, resp, err := client.PullRequests.ListFiles(client.Context, owner, repoName, number, opts)
+		if err != nil {
+			log.Printf("error fetching PR files for '%s/%s': %+v", owner, repoName, err)
+			return nil, err
+		}
+
+		files = append(files, apiFiles...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return files, nil
+}
+
+// Writes a file via a temporary file in the same directory, so a failed
+// write never replaces the existing file.
+func writeFileAtomically(filename string, write func(f *os.File) error) error {
+	f, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if err := write(f); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filename)
+}
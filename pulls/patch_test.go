@@ -0,0 +1,105 @@
+package pulls
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v88/github"
+	"github.com/parkr/github-utils/gh/ghtest"
+)
+
+func TestWritePatchFile(t *testing.T) {
+	const patch = "From 1234567 Mon Sep 17 00:00:00 2001\nSubject: [PATCH] Add greeting\n"
+	client := ghtest.NewClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if accept := r.Header.Get("Accept"); accept != "application/vnd.github.v3.patch" {
+			t.Errorf("expected patch media type, got %q", accept)
+		}
+		switch r.URL.Path {
+		case "/repos/parkr/hello/pulls/1":
+			w.Write([]byte(patch))
+		default:
+			http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+		}
+	})
+
+	pr := func(number int) *github.PullRequest {
+		return &github.PullRequest{
+			Number: github.Int(number),
+			Base:   &github.PullRequestBranch{Repo: &github.Repository{FullName: github.String("parkr/hello")}},
+		}
+	}
+
+	opts := Options{OutputDir: t.TempDir()}
+	filename, err := WritePatchFile(client, opts, pr(1))
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	if contents, _ := os.ReadFile(filename); string(contents) != patch {
+		t.Errorf("expected patch to be written, got %q", contents)
+	}
+
+	missingFilename, err := WritePatchFile(client, opts, pr(2))
+	if err == nil {
+		t.Errorf("expected an error for a missing pull request")
+	}
+	if _, err := os.Stat(missingFilename); !os.IsNotExist(err) {
+		t.Errorf("expected no patch to be written for a missing pull request")
+	}
+
+	opts.MaxPatchSize = 10
+	_, err = WritePatchFile(client, opts, pr(1))
+	if !errors.Is(err, ErrPatchTooLarge) {
+		t.Errorf("expected a size limit error, got %+v", err)
+	}
+	// The earlier patch should be left in place.
+	if contents, _ := os.ReadFile(filename); string(contents) != patch {
+		t.Errorf("expected previous patch to be kept, got %q", contents)
+	}
+}
+
+func TestWritePullRequestSkipsLargePatches(t *testing.T) {
+	client := ghtest.NewClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/repos/parkr/hello/pulls/1":
+			w.Write([]byte(strings.Repeat("+ a very long line\n", 100)))
+		case r.URL.Path == "/user":
+			w.Write([]byte(`{"login": "parkr"}`))
+		case strings.HasPrefix(r.URL.Path, "/repos/parkr/hello/"):
+			w.Write([]byte(`[]`))
+		default:
+			http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+		}
+	})
+
+	writer, err := NewMessageWriter("mbox")
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	renderer, err := NewPageRenderer("markdown")
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	opts := Options{OutputDir: t.TempDir(), MaxPatchSize: 10, Writer: writer, Renderer: renderer}
+	pr := &github.PullRequest{
+		Number: github.Int(1),
+		Title:  github.String("Add greeting"),
+		Base:   &github.PullRequestBranch{Repo: &github.Repository{FullName: github.String("parkr/hello")}},
+	}
+
+	status := WritePullRequest(client, opts, "parkr/hello", pr)
+	if !status.Success {
+		t.Fatalf("expected a large patch to be skipped, got %+v", status.Error)
+	}
+	if _, err := os.Stat(opts.PatchFilename(1)); !os.IsNotExist(err) {
+		t.Errorf("expected no patch to be written")
+	}
+	for _, filename := range []string{writer.Filename(opts.OutputDir, 1), renderer.Filename(opts.OutputDir, 1)} {
+		if _, err := os.Stat(filename); err != nil {
+			t.Errorf("expected %s to be written: %+v", filepath.Base(filename), err)
+		}
+	}
+}
@@ -1,38 +1,127 @@
 package pulls
 
 import (
+	"fmt"
 	"log"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/go-github/v88/github"
 	"github.com/parkr/github-utils/gh"
 )
 
-func FetchPullRequests(client *gh.Client, repo string, input chan *github.PullRequest) error {
-	pieces := strings.SplitN(repo, "/", -1)
-	owner, name := pieces[0], pieces[1]
+// Splits a repo NWO like "parkr/github-utils" into its owner and name.
+func splitRepo(repo string) (string, string) {
+	owner, name, _ := strings.Cut(repo, "/")
+	return owner, name
+}
+
+// How many pull requests are written at once when Options.Concurrency isn't
+// set.
+const DefaultConcurrency = 5
+
+// Which pull requests to download.
+type PullRequestFilter struct {
+	// One of "open", "closed", "merged" or "all". Defaults to "open".
+	State string
+	// Only pull requests into this branch, by this user, or with all of
+	// these labels, if set.
+	Base   string
+	Author string
+	Labels []string
+	// Only pull requests updated since this time, if set.
+	UpdatedSince time.Time
+	// At most this many pull requests, most recently updated first. Zero
+	// means there's no limit.
+	Max int
+}
+
+// Reports whether the filter lists every open pull request, so any which were
+// open before but aren't listed must have been closed since.
+func (f PullRequestFilter) ListsAllOpen() bool {
+	return (f.State == "" || f.State == "open" || f.State == "all") &&
+		f.Base == "" && f.Author == "" && len(f.Labels) == 0 &&
+		f.UpdatedSince.IsZero() && f.Max == 0
+}
+
+// Reports whether the pull request matches the filter's author, labels and
+// state. The API handles the rest.
+func (f PullRequestFilter) matches(pr *github.PullRequest) bool {
+	if f.State == "merged" && pr.MergedAt == nil {
+		return false
+	}
+	if f.Author != "" && !strings.EqualFold(pr.GetUser().GetLogin(), f.Author) {
+		return false
+	}
+	for _, label := range f.Labels {
+		hasLabel := false
+		for _, prLabel := range pr.Labels {
+			if strings.EqualFold(prLabel.GetName(), label) {
+				hasLabel = true
+				break
+			}
+		}
+		if !hasLabel {
+			return false
+		}
+	}
+	return true
+}
+
+// Sends the repo's pull requests matching the filter to input, most recently
+// updated first, closing it when done.
+func FetchPullRequests(client *gh.Client, repo string, filter PullRequestFilter, input chan *github.PullRequest) error {
+	defer close(input)
+	owner, name := splitRepo(repo)
 
 	opts := &github.PullRequestListOptions{
-		State:       "open",
-		Sort:        "created",
+		State:       filter.State,
+		Base:        filter.Base,
+		Sort:        "updated",
+		Direction:   "desc",
 		ListOptions: github.ListOptions{PerPage: 100},
 	}
+	switch filter.State {
+	case "":
+		opts.State = "open"
+	case "merged":
+		opts.State = "closed"
+	}
 
+	count := 0
 	for {
 		prs, resp, err := client.PullRequests.List(client.Context, owner, name, opts)
 		if err != nil {
 			log.Printf("error fetching PR's for '%s': %+v", repo, err)
-			close(input)
 			return err
 		}
 
 		for _, pr := range prs {
-			input <- pr
+			if err := client.Context.Err(); err != nil {
+				return err
+			}
+			if !filter.UpdatedSince.IsZero() && pr.GetUpdatedAt().Before(filter.UpdatedSince) {
+				log.Printf("repo(%s): all done! %d pull requests updated since %s", repo, count, filter.UpdatedSince.Format("2006-01-02"))
+				return nil
+			}
+			if !filter.matches(pr) {
+				continue
+			}
+			select {
+			case input <- pr:
+			case <-client.Context.Done():
+				return client.Context.Err()
+			}
+			count++
+			if filter.Max > 0 && count >= filter.Max {
+				log.Printf("repo(%s): all done! reached the maximum of %d pull requests", repo, filter.Max)
+				return nil
+			}
 		}
 
 		if resp.NextPage == 0 {
 			log.Printf("repo(%s): all done! closing up input...", repo)
-			close(input)
 			break
 		}
 		opts.ListOptions.Page = resp.NextPage
@@ -41,42 +130,94 @@ func FetchPullRequests(client *gh.Client, repo string, input chan *github.PullRe
 	return nil
 }
 
-func CachePullRequestsLocally(client *gh.Client, outputDir, repo string, input chan []*github.PullRequest, output chan OfflineStatusResponse) {
-	bridge := make(chan OfflineStatusResponse, 1000)
-	counter := 0
-
-	//
-	for prs := range input {
-		prs := prs
-		counter += len(prs)
-		go func(prs []*github.PullRequest, bridge chan OfflineStatusResponse) {
-			for _, pr := range prs {
-				bridge <- WritePullRequest(client, outputDir, repo, pr)
+// Writes each pull request from input using a pool of opts.Concurrency
+// workers, sending the result of each to output. Output is closed once input
+// is closed and drained. Once client.Context is cancelled, the remaining pull
+// requests are drained from input without being written.
+func CachePullRequestsLocally(client *gh.Client, opts Options, repo string, input <-chan *github.PullRequest, output chan<- OfflineStatusResponse) {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for pr := range input {
+				if client.Context.Err() != nil {
+					continue
+				}
+				output <- WritePullRequest(client, opts, repo, pr)
 			}
-		}(prs, bridge)
+		}()
 	}
+	wg.Wait()
+	close(output)
+}
 
-	log.Printf("Saving %d pull requests...", counter)
+// Tallies the results of writing a repo's pull requests.
+type CacheSummary struct {
+	Succeeded, Skipped, Failed int
+	BytesWritten               int64
+}
 
-	// Now wait for all of them to process.
-	for status := range bridge {
-		counter--
-		output <- status
-		if counter == 0 {
-			break
+func (s *CacheSummary) Add(resp OfflineStatusResponse) {
+	switch {
+	case resp.Skipped:
+		s.Skipped++
+	case resp.Success:
+		s.Succeeded++
+	default:
+		s.Failed++
+	}
+	s.BytesWritten += resp.BytesWritten
+}
+
+func (s CacheSummary) String() string {
+	return fmt.Sprintf("%d written, %d unchanged, %d failed, %d bytes written", s.Succeeded, s.Skipped, s.Failed, s.BytesWritten)
+}
+
+// Fetches the repo's pull requests matching the filter and writes them with
+// CachePullRequestsLocally, passing each result to report, if it's given.
+// The error is from fetching the pull requests, or client.Context being
+// cancelled; pull requests which fail to be written are counted in the
+// summary instead.
+func ArchivePullRequests(client *gh.Client, opts Options, repo string, filter PullRequestFilter, report func(OfflineStatusResponse)) (CacheSummary, error) {
+	input := make(chan *github.PullRequest, 100)
+	output := make(chan OfflineStatusResponse)
+	fetchErr := make(chan error, 1)
+
+	go func() { fetchErr <- FetchPullRequests(client, repo, filter, input) }()
+	go CachePullRequestsLocally(client, opts, repo, input, output)
+
+	summary := CacheSummary{}
+	for resp := range output {
+		summary.Add(resp)
+		if report != nil {
+			report(resp)
 		}
 	}
-	close(bridge)
-	close(output)
+
+	if err := <-fetchErr; err != nil {
+		return summary, err
+	}
+	return summary, client.Context.Err()
 }
 
-func GetPullRequestComments(client *gh.Client, owner, repoName string, number int) ([]*github.IssueComment, error) {
+// Lists the comments on the pull request updated since the given time, or
+// all of them if it's zero.
+func GetPullRequestComments(client *gh.Client, owner, repoName string, number int, since time.Time) ([]*github.IssueComment, error) {
 	comments, nwo := []*github.IssueComment{}, owner+"/"+repoName
 	opts := &github.IssueListCommentsOptions{
 		Sort:        github.String("created"),
 		Direction:   github.String("asc"),
 		ListOptions: github.ListOptions{PerPage: 100},
 	}
+	if !since.IsZero() {
+		opts.Since = &since
+	}
 	for {
 		apiComments, resp, err := client.Issues.ListComments(client.Context, owner, repoName, number, opts)
 		if err != nil {
@@ -94,11 +235,14 @@ func GetPullRequestComments(client *gh.Client, owner, repoName string, number in
 	return comments, nil
 }
 
-func GetPullRequestLineComments(client *gh.Client, owner, repoName string, number int) ([]*github.PullRequestComment, error) {
+// Lists the line comments on the pull request updated since the given time,
+// or all of them if it's zero.
+func GetPullRequestLineComments(client *gh.Client, owner, repoName string, number int, since time.Time) ([]*github.PullRequestComment, error) {
 	comments, nwo := []*github.PullRequestComment{}, owner+"/"+repoName
 	opts := &github.PullRequestListCommentsOptions{
 		Sort:        "created",
 		Direction:   "asc",
+		Since:       since,
 		ListOptions: github.ListOptions{PerPage: 100},
 	}
 	for {
@@ -117,3 +261,23 @@ func GetPullRequestLineComments(client *gh.Client, owner, repoName string, numbe
 	}
 	return comments, nil
 }
+
+func GetPullRequestReviews(client *gh.Client, owner, repoName string, number int) ([]*github.PullRequestReview, error) {
+	reviews, nwo := []*github.PullRequestReview{}, owner+"/"+repoName
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		apiReviews, resp, err := client.PullRequests.ListReviews(client.Context, owner, repoName, number, opts)
+		if err != nil {
+			log.Printf("error fetching PR reviews for '%s': %+v", nwo, err)
+			return nil, err
+		}
+
+		reviews = append(reviews, apiReviews...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return reviews, nil
+}
@@ -0,0 +1,80 @@
+package pulls
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v88/github"
+	"github.com/parkr/github-utils/gh"
+)
+
+func TestPullRequestFilterMatches(t *testing.T) {
+	pr := &github.PullRequest{
+		User:   &github.User{Login: github.String("parkr")},
+		Labels: []*github.Label{{Name: github.String("bug")}, {Name: github.String("help wanted")}},
+	}
+	merged := &github.PullRequest{
+		User:     &github.User{Login: github.String("jekyllbot")},
+		MergedAt: &github.Timestamp{},
+	}
+
+	for _, test := range []struct {
+		filter   PullRequestFilter
+		pr       *github.PullRequest
+		expected bool
+	}{
+		{PullRequestFilter{}, pr, true},
+		{PullRequestFilter{Author: "Parkr"}, pr, true},
+		{PullRequestFilter{Author: "jekyllbot"}, pr, false},
+		{PullRequestFilter{Labels: []string{"bug", "Help Wanted"}}, pr, true},
+		{PullRequestFilter{Labels: []string{"bug", "enhancement"}}, pr, false},
+		{PullRequestFilter{State: "merged"}, pr, false},
+		{PullRequestFilter{State: "merged"}, merged, true},
+		{PullRequestFilter{State: "closed"}, merged, true},
+	} {
+		if actual := test.filter.matches(test.pr); actual != test.expected {
+			t.Errorf("%+v: expected %v, got %v", test.filter, test.expected, actual)
+		}
+	}
+}
+
+func TestPullRequestFilterListsAllOpen(t *testing.T) {
+	for _, test := range []struct {
+		filter   PullRequestFilter
+		expected bool
+	}{
+		{PullRequestFilter{}, true},
+		{PullRequestFilter{State: "open"}, true},
+		{PullRequestFilter{State: "all"}, true},
+		{PullRequestFilter{State: "closed"}, false},
+		{PullRequestFilter{State: "merged"}, false},
+		{PullRequestFilter{Base: "main"}, false},
+		{PullRequestFilter{Author: "parkr"}, false},
+		{PullRequestFilter{Labels: []string{"bug"}}, false},
+		{PullRequestFilter{UpdatedSince: time.Now()}, false},
+		{PullRequestFilter{Max: 10}, false},
+	} {
+		if actual := test.filter.ListsAllOpen(); actual != test.expected {
+			t.Errorf("%+v: expected %v, got %v", test.filter, test.expected, actual)
+		}
+	}
+}
+
+func TestCachePullRequestsLocallyWithNoPullRequests(t *testing.T) {
+	client := &gh.Client{Context: context.Background()}
+	input := make(chan *github.PullRequest)
+	output := make(chan OfflineStatusResponse)
+	close(input)
+
+	go CachePullRequestsLocally(client, Options{OutputDir: t.TempDir()}, "parkr/hello", input, output)
+
+	select {
+	case resp, ok := <-output:
+		if ok {
+			t.Errorf("expected no results, got %+v", resp)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected output to be closed")
+	}
+}
@@ -0,0 +1,213 @@
+package pulls
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v88/github"
+	"github.com/parkr/github-utils/gh"
+)
+
+// The name of the file in the output directory which records what has been
+// downloaded, so subsequent runs only fetch what changed.
+const SyncStateFilename = ".github-offline-sync.json"
+
+// What was last downloaded for a single pull request.
+type PullRequestSyncState struct {
+	UpdatedAt time.Time `json:"updated_at"`
+	HeadSHA   string    `json:"head_sha"`
+	// One of "open", "closed" or "merged".
+	State string `json:"state"`
+
+	// Describe the pull request on index pages and in search results,
+	// without it having to be listed again.
+	Title     string    `json:"title,omitempty"`
+	Author    string    `json:"author,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitzero"`
+}
+
+// What was last downloaded for a repo.
+type RepoSyncState struct {
+	LastSync     time.Time                     `json:"last_sync"`
+	PullRequests map[int]*PullRequestSyncState `json:"pull_requests"`
+}
+
+// The sync state for every repo downloaded into an output directory. It's
+// safe for concurrent use.
+type SyncState struct {
+	Repos map[string]*RepoSyncState `json:"repos"`
+
+	// Full ignores what was previously downloaded, so everything is fetched
+	// again, while still recording this sync.
+	Full bool `json:"-"`
+
+	filename string
+	mu       sync.Mutex
+}
+
+// Reads the sync state from the output directory. If there isn't any, an
+// empty state is returned and everything will be downloaded.
+func LoadSyncState(outputDir string) (*SyncState, error) {
+	state := &SyncState{
+		Repos:    map[string]*RepoSyncState{},
+		filename: filepath.Join(outputDir, SyncStateFilename),
+	}
+
+	contents, err := os.ReadFile(state.filename)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(contents, state); err != nil {
+		return nil, err
+	}
+	if state.Repos == nil {
+		state.Repos = map[string]*RepoSyncState{}
+	}
+	return state, nil
+}
+
+// Writes the sync state back to the output directory.
+func (s *SyncState) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	contents, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.filename, contents, 0600)
+}
+
+func (s *SyncState) repo(repo string) *RepoSyncState {
+	if s.Repos[repo] == nil {
+		s.Repos[repo] = &RepoSyncState{PullRequests: map[int]*PullRequestSyncState{}}
+	}
+	return s.Repos[repo]
+}
+
+func (s *SyncState) pullRequest(repo string, number int) (PullRequestSyncState, bool) {
+	if s == nil || s.Full {
+		return PullRequestSyncState{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prState, ok := s.repo(repo).PullRequests[number]
+	if !ok {
+		return PullRequestSyncState{}, false
+	}
+	return *prState, true
+}
+
+// Reports whether the pull request changed since it was last downloaded.
+// A nil state always needs syncing.
+func (s *SyncState) NeedsSync(repo string, pr *github.PullRequest) bool {
+	prState, ok := s.pullRequest(repo, pr.GetNumber())
+	return !ok || !prState.UpdatedAt.Equal(pr.GetUpdatedAt().Time)
+}
+
+// Reports whether the pull request's head commit changed since it was last
+// downloaded, meaning its patch needs to be rewritten.
+func (s *SyncState) HeadChanged(repo string, pr *github.PullRequest) bool {
+	prState, ok := s.pullRequest(repo, pr.GetNumber())
+	return !ok || prState.HeadSHA != pr.GetHead().GetSHA()
+}
+
+// Returns when the pull request was last downloaded, so only comments since
+// then need to be fetched. The zero time means everything should be fetched.
+func (s *SyncState) Since(repo string, pr *github.PullRequest) time.Time {
+	prState, _ := s.pullRequest(repo, pr.GetNumber())
+	return prState.UpdatedAt
+}
+
+// Records that the pull request has been downloaded.
+func (s *SyncState) Record(repo string, pr *github.PullRequest) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.repo(repo).PullRequests[pr.GetNumber()] = &PullRequestSyncState{
+		UpdatedAt: pr.GetUpdatedAt().Time,
+		HeadSHA:   pr.GetHead().GetSHA(),
+		State:     pullRequestState(pr),
+		Title:     pr.GetTitle(),
+		Author:    pr.GetUser().GetLogin(),
+		CreatedAt: pr.GetCreatedAt().Time,
+	}
+}
+
+// Returns what was last downloaded for each of the repo's pull requests, by
+// number.
+func (s *SyncState) PullRequests(repo string) map[int]PullRequestSyncState {
+	prStates := map[int]PullRequestSyncState{}
+	if s == nil {
+		return prStates
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if repoState, ok := s.Repos[repo]; ok {
+		for number, prState := range repoState.PullRequests {
+			prStates[number] = *prState
+		}
+	}
+	return prStates
+}
+
+// Records that the repo finished syncing at the given time.
+func (s *SyncState) Finish(repo string, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.repo(repo).LastSync = at
+}
+
+// Finds pull requests which were open as of the last sync, but weren't among
+// the open pull requests seen this time, and records whether they were
+// closed or merged. The pull requests which changed state are returned.
+func (s *SyncState) MarkClosed(client *gh.Client, repo string, seen map[int]bool) ([]*github.PullRequest, error) {
+	owner, name := splitRepo(repo)
+
+	s.mu.Lock()
+	missing := []int{}
+	for number, prState := range s.repo(repo).PullRequests {
+		if prState.State == "open" && !seen[number] {
+			missing = append(missing, number)
+		}
+	}
+	s.mu.Unlock()
+
+	closed := []*github.PullRequest{}
+	for _, number := range missing {
+		pr, _, err := client.PullRequests.Get(client.Context, owner, name, number)
+		if err != nil {
+			return closed, err
+		}
+		if pr.GetState() == "open" {
+			continue
+		}
+
+		s.mu.Lock()
+		prState := s.repo(repo).PullRequests[number]
+		prState.State = pullRequestState(pr)
+		s.mu.Unlock()
+
+		closed = append(closed, pr)
+	}
+	return closed, nil
+}
+
+func pullRequestState(pr *github.PullRequest) string {
+	if pr.GetMerged() || pr.MergedAt != nil {
+		return "merged"
+	}
+	return pr.GetState()
+}
@@ -0,0 +1,190 @@
+package pulls
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v88/github"
+	"github.com/parkr/github-utils/gh/ghtest"
+)
+
+func syncTestPullRequest(number int, sha string, updatedAt time.Time) *github.PullRequest {
+	return &github.PullRequest{
+		Number:    github.Int(number),
+		State:     github.String("open"),
+		UpdatedAt: &github.Timestamp{Time: updatedAt},
+		Head:      &github.PullRequestBranch{SHA: github.String(sha)},
+	}
+}
+
+func TestSyncState(t *testing.T) {
+	updatedAt := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+	recorded := syncTestPullRequest(1, "abc", updatedAt)
+
+	for _, test := range []struct {
+		name                   string
+		pr                     *github.PullRequest
+		full                   bool
+		needsSync, headChanged bool
+		expectedSince          time.Time
+	}{
+		{"unchanged", syncTestPullRequest(1, "abc", updatedAt), false, false, false, updatedAt},
+		{"commented on", syncTestPullRequest(1, "abc", updatedAt.Add(time.Hour)), false, true, false, updatedAt},
+		{"pushed to", syncTestPullRequest(1, "def", updatedAt.Add(time.Hour)), false, true, true, updatedAt},
+		{"never downloaded", syncTestPullRequest(2, "abc", updatedAt), false, true, true, time.Time{}},
+		{"full sync", syncTestPullRequest(1, "abc", updatedAt), true, true, true, time.Time{}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			state, err := LoadSyncState(dir)
+			if err != nil {
+				t.Fatalf("unexpected error: %+v", err)
+			}
+			state.Record("parkr/hello", recorded)
+			if err := state.Save(); err != nil {
+				t.Fatalf("unexpected error: %+v", err)
+			}
+
+			// Only what was saved should be used.
+			state, err = LoadSyncState(dir)
+			if err != nil {
+				t.Fatalf("unexpected error: %+v", err)
+			}
+			state.Full = test.full
+
+			if actual := state.NeedsSync("parkr/hello", test.pr); actual != test.needsSync {
+				t.Errorf("expected NeedsSync to be %v, got %v", test.needsSync, actual)
+			}
+			if actual := state.HeadChanged("parkr/hello", test.pr); actual != test.headChanged {
+				t.Errorf("expected HeadChanged to be %v, got %v", test.headChanged, actual)
+			}
+			if actual := state.Since("parkr/hello", test.pr); !actual.Equal(test.expectedSince) {
+				t.Errorf("expected Since to be %v, got %v", test.expectedSince, actual)
+			}
+		})
+	}
+}
+
+func TestSyncStateWithoutState(t *testing.T) {
+	var state *SyncState
+	pr := syncTestPullRequest(1, "abc", time.Now())
+	if !state.NeedsSync("parkr/hello", pr) || !state.HeadChanged("parkr/hello", pr) {
+		t.Errorf("expected a nil state to always need syncing")
+	}
+	if since := state.Since("parkr/hello", pr); !since.IsZero() {
+		t.Errorf("expected a nil state to fetch everything, got %v", since)
+	}
+	state.Record("parkr/hello", pr)
+}
+
+func TestSyncStateMarkClosed(t *testing.T) {
+	client := ghtest.NewClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/parkr/hello/pulls/2":
+			w.Write([]byte(`{"number": 2, "state": "open"}`))
+		case "/repos/parkr/hello/pulls/3":
+			w.Write([]byte(`{"number": 3, "state": "closed", "merged": true, "merged_at": "2018-01-02T00:00:00Z"}`))
+		case "/repos/parkr/hello/pulls/4":
+			w.Write([]byte(`{"number": 4, "state": "closed"}`))
+		default:
+			t.Errorf("unexpected request for %s", r.URL.Path)
+			http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+		}
+	})
+
+	dir := t.TempDir()
+	state, err := LoadSyncState(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	updatedAt := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	for number := 1; number <= 4; number++ {
+		state.Record("parkr/hello", syncTestPullRequest(number, "abc", updatedAt))
+	}
+	// Pull requests already known to be closed aren't checked again.
+	closedBefore := syncTestPullRequest(5, "abc", updatedAt)
+	closedBefore.State = github.String("closed")
+	state.Record("parkr/hello", closedBefore)
+
+	// #1 was listed, and #2 is still open, just not listed.
+	closed, err := state.MarkClosed(client, "parkr/hello", map[int]bool{1: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	numbers := map[int]bool{}
+	for _, pr := range closed {
+		numbers[pr.GetNumber()] = true
+	}
+	if len(closed) != 2 || !numbers[3] || !numbers[4] {
+		t.Errorf("expected #3 and #4 to be closed, got %v", numbers)
+	}
+
+	if err := state.Save(); err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	state, err = LoadSyncState(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	for number, expected := range map[int]string{1: "open", 2: "open", 3: "merged", 4: "closed", 5: "closed"} {
+		if actual := state.Repos["parkr/hello"].PullRequests[number].State; actual != expected {
+			t.Errorf("expected #%d to be %s, got %s", number, expected, actual)
+		}
+	}
+}
+
+func TestWritePullRequestAfterSwitchingFormats(t *testing.T) {
+	client := ghtest.NewClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/user":
+			w.Write([]byte(`{"login": "parkr"}`))
+		case "/repos/parkr/hello/issues/1/comments":
+			// The early comment was written to the old format's file, so
+			// it's only listed when every comment is fetched.
+			if r.URL.Query().Get("since") != "" {
+				w.Write([]byte(`[]`))
+				return
+			}
+			w.Write([]byte(`[{"id": 2, "body": "An early comment", "user": {"login": "jekyllbot"}, "created_at": "2018-01-01T01:00:00Z"}]`))
+		default:
+			w.Write([]byte(`[]`))
+		}
+	})
+
+	dir := t.TempDir()
+	state, err := LoadSyncState(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	pr := syncTestPullRequest(1, "abc", time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC))
+	pr.Title = github.String("Add greeting")
+	state.Record("parkr/hello", pr)
+
+	opts := Options{OutputDir: dir, Writer: MaildirWriter{}, State: state}
+	if err := os.WriteFile(opts.PatchFilename(1), []byte("patch"), 0644); err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	if resp := WritePullRequest(client, opts, "parkr/hello", pr); !resp.Success || resp.Skipped {
+		t.Fatalf("expected the pull request to be written, got %+v", resp)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, "1", "cur"))
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	var contents string
+	for _, entry := range entries {
+		message, err := os.ReadFile(filepath.Join(dir, "1", "cur", entry.Name()))
+		if err != nil {
+			t.Fatalf("unexpected error: %+v", err)
+		}
+		contents += string(message)
+	}
+	if !strings.Contains(contents, "An early comment") {
+		t.Errorf("expected the maildir to contain the early comment, got %d messages:\n%s", len(entries), contents)
+	}
+}
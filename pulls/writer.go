@@ -0,0 +1,62 @@
+package pulls
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+)
+
+// A MessageWriter stores a pull request's messages on the local disk. Writes
+// are idempotent: messages whose Message-ID has already been written are
+// skipped, so re-running doesn't duplicate them.
+type MessageWriter interface {
+	// Returns the path the messages for the pull request are written to.
+	Filename(outputDir string, number int) string
+
+	// Writes any of the messages not already written, returning the path they
+	// were written to.
+	WriteMessages(outputDir string, number int, comments Comments) (string, error)
+}
+
+// The names of the available message formats, for use with NewMessageWriter.
+var MessageFormats = []string{"mbox", "maildir"}
+
+// Returns the MessageWriter for the named format.
+func NewMessageWriter(format string) (MessageWriter, error) {
+	switch format {
+	case "mbox":
+		return MboxWriter{}, nil
+	case "maildir":
+		return MaildirWriter{}, nil
+	default:
+		return nil, fmt.Errorf("unknown message format %q, expected one of: %s", format, strings.Join(MessageFormats, ", "))
+	}
+}
+
+// Write the comment's RFC 5322 headers, followed by the blank line which
+// separates them from the body.
+func writeMessageHeaders(comment Comment, buf io.Writer) error {
+	mailtime := comment.CreatedAt.Format("Mon, 2 Jan 2006 15:04:05 -0700")
+	fmt.Fprintf(buf, "Return-Path: <%s>\n", comment.Email())
+	fmt.Fprintf(buf, "Delivered-To: %s\n", comment.To)
+	fmt.Fprintf(buf, "Envelope-To: %s\n", comment.To)
+	fmt.Fprintf(buf, "Delivery-Date: %s\n", mailtime)
+	fmt.Fprintf(buf, "From: %s\n", comment.From())
+	fmt.Fprintf(buf, "To: %s\n", comment.To)
+	fmt.Fprintf(buf, "Subject: %s\n", mime.QEncoding.Encode("utf-8", comment.Subject))
+	fmt.Fprintf(buf, "Date: %s\n", mailtime)
+	if comment.MessageID != "" {
+		fmt.Fprintf(buf, "Message-ID: %s\n", comment.MessageID)
+	}
+	if comment.InReplyTo != "" {
+		fmt.Fprintf(buf, "In-Reply-To: %s\n", comment.InReplyTo)
+	}
+	if len(comment.References) > 0 {
+		fmt.Fprintf(buf, "References: %s\n", strings.Join(comment.References, " "))
+	}
+	fmt.Fprintf(buf, "MIME-Version: 1.0\n")
+	fmt.Fprintf(buf, "Content-Type: text/plain; charset=utf-8\n")
+	_, err := fmt.Fprintf(buf, "\n")
+	return err
+}
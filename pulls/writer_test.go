@@ -0,0 +1,48 @@
+package pulls
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMessageWritersAreIdempotent(t *testing.T) {
+	comments := Comments{
+		{Username: "parkr", Subject: "Add greeting", Message: "Hi!", CreatedAt: time.Unix(1000, 0), MessageID: messageID("parkr/hello", 1, "", 0)},
+		{Username: "jekyllbot", Subject: "Re: Add greeting", Message: "Thanks!", CreatedAt: time.Unix(2000, 0), MessageID: messageID("parkr/hello", 1, "c", 2)},
+	}
+
+	for _, format := range MessageFormats {
+		writer, err := NewMessageWriter(format)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %+v", format, err)
+		}
+		dir := t.TempDir()
+		for i := 0; i < 2; i++ {
+			if _, err := writer.WriteMessages(dir, 1, comments); err != nil {
+				t.Fatalf("%s: unexpected error: %+v", format, err)
+			}
+		}
+
+		var count int
+		switch format {
+		case "mbox":
+			contents, err := os.ReadFile(filepath.Join(dir, "1.mbox"))
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %+v", format, err)
+			}
+			count = strings.Count(string(contents), "\nMessage-ID: ")
+		case "maildir":
+			entries, err := os.ReadDir(filepath.Join(dir, "1", "cur"))
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %+v", format, err)
+			}
+			count = len(entries)
+		}
+		if count != len(comments) {
+			t.Errorf("%s: expected %d messages after writing twice, got %d", format, len(comments), count)
+		}
+	}
+}
@@ -12,42 +12,38 @@ var (
 	fromLineRegexp = regexp.MustCompile(`^From @(\S+)(\:|'s radar comments)`)
 )
 
-var graphQLQueryIssuesAndComments = `{
+var graphQLQueryIssueAndComments = `{
     repository(owner: "%s", name: "%s") {
-        issues(labels: ["radar"], states: [OPEN], last: 1) {
-          nodes {
-            url
-            body
-            comments(first: 100) {
-              nodes {
-                author {
-                  login
-                }
-                body
+        issue(number: %d) {
+          url
+          body
+          comments(first: 100) {
+            nodes {
+              author {
+                login
               }
+              body
             }
           }
         }
       }
 }`
 
-type issuesAndComments struct {
+type issueAndComments struct {
 	Data struct {
 		Repository struct {
-			Issues struct {
-				Nodes []struct {
-					URL      string `json:"url"`
-					Body     string `json:"body"`
-					Comments struct {
-						Nodes []struct {
-							Author struct {
-								Login string `json:"login"`
-							} `json:"author"`
-							Body string `json:"body"`
-						} `json:"nodes"`
-					} `json:"comments"`
-				} `json:"nodes"`
-			} `json:"issues"`
+			Issue struct {
+				URL      string `json:"url"`
+				Body     string `json:"body"`
+				Comments struct {
+					Nodes []struct {
+						Author struct {
+							Login string `json:"login"`
+						} `json:"author"`
+						Body string `json:"body"`
+					} `json:"nodes"`
+				} `json:"comments"`
+			} `json:"issue"`
 		} `json:"repository"`
 	} `json:"data"`
 }
@@ -70,7 +66,7 @@ func (r *Radar) AddDefaultParagraphs(ctx context.Context) error {
 
 func (r *Radar) PreviouslyParagraph(ctx context.Context) string {
 	if issue := r.GetPrevious(ctx); issue != nil {
-		return fmt.Sprintf("*[Previously](%s).*", r.GetPrevious(ctx).GetHTMLURL())
+		return fmt.Sprintf("*[Previously](%s).*", issue.GetHTMLURL())
 	}
 	return ""
 }
@@ -82,10 +78,17 @@ func (r *Radar) WhatsUpParagraph() string {
 func (r *Radar) PreviousTasksParagraphs(ctx context.Context) ([]string, error) {
 	previousTasks := map[string][]string{}
 
-	var data issuesAndComments
+	// Tasks are carried over from the previous week's issue, not this week's
+	// if it's being updated.
+	previous := r.GetPrevious(ctx)
+	if previous == nil {
+		return []string{}, nil
+	}
+
+	var data issueAndComments
 	req, err := r.github.NewRequest(ctx, "POST", "graphql", struct {
 		Query string `json:"query"`
-	}{Query: fmt.Sprintf(graphQLQueryIssuesAndComments, r.repoOwner, r.repoName)})
+	}{Query: fmt.Sprintf(graphQLQueryIssueAndComments, r.repoOwner, r.repoName, previous.GetNumber())})
 	if err != nil {
 		return []string{}, err
 	}
@@ -93,11 +96,10 @@ func (r *Radar) PreviousTasksParagraphs(ctx context.Context) ([]string, error) {
 	if err != nil {
 		return []string{}, err
 	}
-	for _, issue := range data.Data.Repository.Issues.Nodes {
-		r.parseBodyForTasks(issue.Body, previousTasks, "")
-		for _, comments := range issue.Comments.Nodes {
-			r.parseBodyForTasks(comments.Body, previousTasks, comments.Author.Login)
-		}
+	issue := data.Data.Repository.Issue
+	r.parseBodyForTasks(issue.Body, previousTasks, "")
+	for _, comments := range issue.Comments.Nodes {
+		r.parseBodyForTasks(comments.Body, previousTasks, comments.Author.Login)
 	}
 
 	var paragraphs []string
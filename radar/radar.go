@@ -2,7 +2,9 @@ package radar
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"regexp"
 	"strings"
 	"time"
 
@@ -16,6 +18,8 @@ type RadarConfig struct {
 	Mention   string
 	RepoOwner string
 	RepoName  string
+	// Create a new issue even if there's already one for this week.
+	Force bool
 }
 
 type Radar struct {
@@ -23,54 +27,150 @@ type Radar struct {
 	mention   string
 	repoOwner string
 	repoName  string
+	force     bool
 
 	paragraphs []string
+	openIssues []*github.Issue
 	previous   *github.Issue
+	current    *github.Issue
+	// This week's issue, when -force posted a new one in its place.
+	replaced *github.Issue
 }
 
+// Matches the date in a radar issue's title.
+var titleRegexp = regexp.MustCompile(`^Radar: Week of (\d{4}-\d{2}-\d{2})$`)
+
 func NewRadar(cfg *RadarConfig) *Radar {
 	return &Radar{
 		github:    cfg.GitHub,
 		mention:   cfg.Mention,
 		repoOwner: cfg.RepoOwner,
 		repoName:  cfg.RepoName,
+		force:     cfg.Force,
 	}
 }
 
-func (r *Radar) GetPrevious(ctx context.Context) *github.Issue {
-	if r.previous == nil {
-		issues, _, err := r.github.Issues.ListByRepo(ctx, r.repoOwner, r.repoName, &github.IssueListByRepoOptions{
-			State:       "open",
-			Labels:      []string{"radar"},
-			Sort:        "created",
-			Direction:   "desc",
-			ListOptions: github.ListOptions{PerPage: 1},
-		})
-		if err != nil {
-			return nil
-		}
-		if len(issues) == 0 {
-			return nil
+// Lists the open radar issues, most recent first, and finds this week's and
+// the one before it among them.
+func (r *Radar) fetchOpenIssues(ctx context.Context) error {
+	if r.openIssues != nil {
+		return nil
+	}
+
+	issues, _, err := r.github.Issues.ListByRepo(ctx, r.repoOwner, r.repoName, &github.IssueListByRepoOptions{
+		State:       "open",
+		Labels:      []string{"radar"},
+		Sort:        "created",
+		Direction:   "desc",
+		ListOptions: github.ListOptions{PerPage: 10},
+	})
+	if err != nil {
+		return err
+	}
+
+	r.openIssues = append([]*github.Issue{}, issues...)
+	for _, issue := range issues {
+		if r.current == nil && r.isThisWeek(issue) {
+			r.current = issue
+		} else if r.previous == nil {
+			r.previous = issue
 		}
-		r.previous = issues[0]
 	}
+	return nil
+}
 
+// Returns the most recent open radar issue which isn't this week's.
+func (r *Radar) GetPrevious(ctx context.Context) *github.Issue {
+	if err := r.fetchOpenIssues(ctx); err != nil {
+		log.Printf("error fetching radar issues: %v", err)
+		return nil
+	}
 	return r.previous
 }
 
+// Returns the most recent open radar issue, whichever week it's for.
+func (r *Radar) Latest(ctx context.Context) *github.Issue {
+	if err := r.fetchOpenIssues(ctx); err != nil {
+		log.Printf("error fetching radar issues: %v", err)
+		return nil
+	}
+	if len(r.openIssues) == 0 {
+		return nil
+	}
+	return r.openIssues[0]
+}
+
+// Closes the previous radar issue, and any issue for this week a forced
+// create replaced, once this week's is confirmed to exist.
 func (r *Radar) ClosePrevious(ctx context.Context) error {
-	if r.previous == nil {
+	stale := []*github.Issue{}
+	for _, issue := range []*github.Issue{r.previous, r.replaced} {
+		if issue != nil {
+			stale = append(stale, issue)
+		}
+	}
+	if len(stale) == 0 {
 		return nil
 	}
+	if r.current == nil {
+		return fmt.Errorf("not closing %s: there's no radar issue for this week", stale[0].GetHTMLURL())
+	}
 
-	_, _, err := r.github.Issues.Edit(ctx, r.repoOwner, r.repoName, r.previous.GetNumber(), &github.IssueRequest{
-		State: github.String("closed"),
-	})
-	return err
+	current, _, err := r.github.Issues.Get(ctx, r.repoOwner, r.repoName, r.current.GetNumber())
+	if err != nil {
+		return fmt.Errorf("not closing %s: could not confirm %s exists: %w", stale[0].GetHTMLURL(), r.current.GetHTMLURL(), err)
+	}
+	if current.GetState() != "open" {
+		return fmt.Errorf("not closing %s: %s is %s", stale[0].GetHTMLURL(), current.GetHTMLURL(), current.GetState())
+	}
+
+	for _, issue := range stale {
+		if issue.GetNumber() == current.GetNumber() {
+			continue
+		}
+		_, _, err = r.github.Issues.Edit(ctx, r.repoOwner, r.repoName, issue.GetNumber(), &github.IssueRequest{
+			State: github.String("closed"),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The Monday of the week the radar is for.
+func (r *Radar) WeekOf() time.Time {
+	now := time.Now().UTC()
+	daysSinceMonday := (int(now.Weekday()) + 6) % 7
+	return time.Date(now.Year(), now.Month(), now.Day()-daysSinceMonday, 0, 0, 0, 0, time.UTC)
 }
 
 func (r *Radar) Title() string {
-	return "Radar: Week of " + time.Now().Format("2006-01-02")
+	return "Radar: Week of " + r.WeekOf().Format("2006-01-02")
+}
+
+// Marks the body of this week's issue, so it's found even if it's renamed.
+func (r *Radar) marker() string {
+	return "<!-- radar: week of " + r.WeekOf().Format("2006-01-02") + " -->"
+}
+
+// Reports whether the issue is this week's, by its marker or the date in its
+// title. Issues from before weeks started on Mondays are dated the day they
+// were created, so any date this week counts.
+func (r *Radar) isThisWeek(issue *github.Issue) bool {
+	if strings.Contains(issue.GetBody(), r.marker()) {
+		return true
+	}
+	matches := titleRegexp.FindStringSubmatch(issue.GetTitle())
+	if matches == nil {
+		return false
+	}
+	date, err := time.Parse("2006-01-02", matches[1])
+	if err != nil {
+		return false
+	}
+	weekOf := r.WeekOf()
+	return !date.Before(weekOf) && date.Before(weekOf.AddDate(0, 0, 7))
 }
 
 func (r *Radar) AddParagraph(paragraph string) {
@@ -90,14 +190,35 @@ func (r *Radar) Body(ctx context.Context) string {
 	return strings.Join(r.paragraphs, "\n\n")
 }
 
+// Creates this week's radar issue. If there already is one, its body is
+// updated instead, unless the radar is forced to create a new one, in which
+// case ClosePrevious closes the existing one along with last week's.
 func (r *Radar) Create(ctx context.Context) (*github.Issue, error) {
-	// Make sure we populate the previous issue so we can close it later.
-	r.GetPrevious(ctx)
+	if err := r.fetchOpenIssues(ctx); err != nil {
+		return nil, err
+	}
+	body := r.Body(ctx) + "\n\n" + r.marker()
+
+	if r.current != nil && !r.force {
+		issue, _, err := r.github.Issues.Edit(ctx, r.repoOwner, r.repoName, r.current.GetNumber(), &github.IssueRequest{
+			Body: github.String(body),
+		})
+		if err != nil {
+			return nil, err
+		}
+		r.current = issue
+		return issue, nil
+	}
 
 	issue, _, err := r.github.Issues.Create(ctx, r.repoOwner, r.repoName, &github.IssueRequest{
 		Title:  github.String(r.Title()),
-		Body:   github.String(r.Body(ctx)),
+		Body:   github.String(body),
 		Labels: &[]string{"radar"},
 	})
-	return issue, err
+	if err != nil {
+		return nil, err
+	}
+	r.replaced = r.current
+	r.current = issue
+	return issue, nil
 }
@@ -0,0 +1,112 @@
+package radar
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v88/github"
+	"github.com/parkr/github-utils/gh/ghtest"
+)
+
+func TestCreateUpdatesThisWeeksIssue(t *testing.T) {
+	r := &Radar{}
+	thisWeek := r.WeekOf().AddDate(0, 0, 2).Format("2006-01-02")
+	lastWeek := r.WeekOf().AddDate(0, 0, -7).Format("2006-01-02")
+
+	requests := []string{}
+	client := ghtest.NewClient(t, func(w http.ResponseWriter, req *http.Request) {
+		requests = append(requests, req.Method+" "+req.URL.Path)
+		switch req.Method + " " + req.URL.Path {
+		case "GET /repos/jekyll/team/issues":
+			fmt.Fprintf(w, `[
+				{"number": 2, "title": "Radar: Week of %s", "state": "open"},
+				{"number": 1, "title": "Radar: Week of %s", "state": "open"}
+			]`, thisWeek, lastWeek)
+		case "POST /graphql":
+			w.Write([]byte(`{"data": {"repository": {"issue": {"body": "- [ ] Ship it", "comments": {"nodes": []}}}}}`))
+		case "PATCH /repos/jekyll/team/issues/2":
+			var body github.IssueRequest
+			json.NewDecoder(req.Body).Decode(&body)
+			if body.Title != nil || body.GetState() != "" {
+				t.Errorf("expected only the body to be updated, got %+v", body)
+			}
+			w.Write([]byte(`{"number": 2, "state": "open"}`))
+		case "GET /repos/jekyll/team/issues/2":
+			w.Write([]byte(`{"number": 2, "state": "open"}`))
+		case "PATCH /repos/jekyll/team/issues/1":
+			w.Write([]byte(`{"number": 1, "state": "closed"}`))
+		default:
+			http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+		}
+	})
+
+	r = NewRadar(&RadarConfig{GitHub: client, Mention: "@jekyll/team", RepoOwner: "jekyll", RepoName: "team"})
+	issue, err := r.Create(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	if issue.GetNumber() != 2 {
+		t.Errorf("expected this week's issue to be updated, got #%d", issue.GetNumber())
+	}
+	if err := r.ClosePrevious(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+
+	expected := []string{
+		"GET /repos/jekyll/team/issues",
+		"POST /graphql",
+		"PATCH /repos/jekyll/team/issues/2",
+		"GET /repos/jekyll/team/issues/2",
+		"PATCH /repos/jekyll/team/issues/1",
+	}
+	if fmt.Sprint(requests) != fmt.Sprint(expected) {
+		t.Errorf("expected requests:\n%v\ngot:\n%v", expected, requests)
+	}
+}
+
+func TestForcedCreateClosesThisAndLastWeeksIssues(t *testing.T) {
+	r := &Radar{}
+	thisWeek := r.WeekOf().Format("2006-01-02")
+	lastWeek := r.WeekOf().AddDate(0, 0, -7).Format("2006-01-02")
+
+	closed := map[string]bool{}
+	client := ghtest.NewClient(t, func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method + " " + req.URL.Path {
+		case "GET /repos/jekyll/team/issues":
+			fmt.Fprintf(w, `[
+				{"number": 2, "title": "Radar: Week of %s", "state": "open"},
+				{"number": 1, "title": "Radar: Week of %s", "state": "open"}
+			]`, thisWeek, lastWeek)
+		case "POST /graphql":
+			w.Write([]byte(`{"data": {"repository": {"issue": {"body": "", "comments": {"nodes": []}}}}}`))
+		case "POST /repos/jekyll/team/issues":
+			w.Write([]byte(`{"number": 3, "state": "open"}`))
+		case "GET /repos/jekyll/team/issues/3":
+			w.Write([]byte(`{"number": 3, "state": "open"}`))
+		case "PATCH /repos/jekyll/team/issues/1", "PATCH /repos/jekyll/team/issues/2":
+			closed[req.URL.Path] = true
+			w.Write([]byte(`{"state": "closed"}`))
+		default:
+			t.Errorf("unexpected request: %s %s", req.Method, req.URL.Path)
+			http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+		}
+	})
+
+	r = NewRadar(&RadarConfig{GitHub: client, Mention: "@jekyll/team", RepoOwner: "jekyll", RepoName: "team", Force: true})
+	issue, err := r.Create(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	if issue.GetNumber() != 3 {
+		t.Errorf("expected a new issue to be created, got #%d", issue.GetNumber())
+	}
+	if err := r.ClosePrevious(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	if !closed["/repos/jekyll/team/issues/1"] || !closed["/repos/jekyll/team/issues/2"] {
+		t.Errorf("expected #1 and #2 to be closed, got %v", closed)
+	}
+}